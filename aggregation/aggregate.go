@@ -0,0 +1,266 @@
+package aggregation
+
+import (
+	"fmt"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/internal/parallel"
+	"github.com/vocdoni/go-snark/types"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+// Commitment is the pair (T, U) of elements of GT that commits to vectors of
+// points, with the keys of the a and b powers of the SRS respectively
+type Commitment struct {
+	T *bn256.GT
+	U *bn256.GT
+}
+
+func (c Commitment) marshal() []byte {
+	return append(c.T.Marshal(), c.U.Marshal()...)
+}
+
+// Round contains the cross terms of a round of the inner product arguments,
+// for the left (L) and right (R) halves of the vectors: the pairing products
+// of A and B (ZAB), the multi-scalar multiplications of C (ZC), and the
+// commitments of A and B (ComAB) and of C (ComC)
+type Round struct {
+	ZABL  *bn256.GT
+	ZABR  *bn256.GT
+	ZCL   *bn256.G1
+	ZCR   *bn256.G1
+	ComAB [2]Commitment
+	ComC  [2]Commitment
+}
+
+func (r *Round) marshal() []byte {
+	b := append(r.ZABL.Marshal(), r.ZABR.Marshal()...)
+	b = append(b, r.ZCL.Marshal()...)
+	b = append(b, r.ZCR.Marshal()...)
+	for _, c := range []Commitment{r.ComAB[0], r.ComAB[1], r.ComC[0], r.ComC[1]} {
+		b = append(b, c.marshal()...)
+	}
+	return b
+}
+
+// Proof is the aggregate proof of n Groth16 proofs, with log2(n) rounds. ComAB
+// and ComC are the commitments of the A, B and C points of the proofs, ZAB is
+// prod(e(A_i, B_i)^(r^i)) and ZC is sum(r^i·C_i), for the random r derived
+// from the commitments. The rounds prove ZAB and ZC with the TIPP and MIPP
+// inner product arguments, ending with the single points A, B and C and the
+// commitment keys VKey and WKey, whose KZG openings are VKeyOpening and
+// WKeyOpening.
+type Proof struct {
+	ComAB       Commitment
+	ComC        Commitment
+	ZAB         *bn256.GT
+	ZC          *bn256.G1
+	Rounds      []Round
+	A           *bn256.G1
+	B           *bn256.G2
+	C           *bn256.G1
+	VKey        [2]*bn256.G2
+	WKey        [2]*bn256.G1
+	VKeyOpening [2]*bn256.G2
+	WKeyOpening [2]*bn256.G1
+}
+
+// appendFinal appends the final values of the rounds to the transcript
+func (p *Proof) appendFinal(t *transcript) {
+	t.append(p.A.Marshal(), p.B.Marshal(), p.C.Marshal(), p.VKey[0].Marshal(),
+		p.VKey[1].Marshal(), p.WKey[0].Marshal(), p.WKey[1].Marshal())
+}
+
+// Aggregate generates the aggregate proof of the Groth16 proofs of the same
+// verification key, where inputs[i] are the public inputs of proofs[i]. The
+// number of proofs is padded to the next power of two, repeating the last
+// proof, which must not be greater than the SRS N. The proofs are not
+// verified, the aggregate proof of invalid proofs is not valid.
+func Aggregate(srs *SRS, vk *types.Vk, proofs []*types.Proof,
+	inputs [][]*big.Int) (*Proof, error) {
+	if len(proofs) != len(inputs) {
+		return nil, fmt.Errorf("got %d proofs and %d public inputs", len(proofs), len(inputs))
+	}
+	for i := range proofs {
+		if err := verifier.ValidateProof(proofs[i]); err != nil {
+			return nil, fmt.Errorf("proof %d: %w", i, err)
+		}
+	}
+	n, err := checkStatement(srs.N, vk, inputs)
+	if err != nil {
+		return nil, err
+	}
+	inputs = padInputs(inputs, n)
+	a := make([]*bn256.G1, n)
+	b := make([]*bn256.G2, n)
+	c := make([]*bn256.G1, n)
+	for i := range a {
+		// the points are copied, so the padding does not repeat pointers
+		p := proofs[len(proofs)-1]
+		if i < len(proofs) {
+			p = proofs[i]
+		}
+		a[i] = new(bn256.G1).Set(p.A)
+		b[i] = new(bn256.G2).Set(p.B)
+		c[i] = new(bn256.G1).Set(p.C)
+	}
+
+	// commitment keys
+	v1, v2 := srs.HAlpha[:n], srs.HBeta[:n]
+	w1, w2 := srs.GAlpha[n:2*n], srs.GBeta[n:2*n]
+
+	proof := &Proof{
+		ComAB: commitPair(v1, v2, w1, w2, a, b),
+		ComC:  commitSingle(v1, v2, c),
+	}
+	t := newTranscript()
+	t.appendStatement(n, vk, inputs)
+	t.append(proof.ComAB.marshal(), proof.ComC.marshal())
+	r := t.challenge()
+	rInv := inverse(r)
+
+	// A, C and v are rescaled by r^i, r^i and r^-i, so the commitments of A
+	// and C do not change, ZAB is the pairing product of the rescaled A and B
+	// and ZC is the sum of the rescaled C
+	rPowers := powers(r, n)
+	rInvPowers := powers(rInv, n)
+	aR := make([]*bn256.G1, n)
+	cR := make([]*bn256.G1, n)
+	v1R := make([]*bn256.G2, n)
+	v2R := make([]*bn256.G2, n)
+	ones := make([]*big.Int, n)
+	parallel.For(n, func(i int) {
+		aR[i] = new(bn256.G1).ScalarMult(a[i], rPowers[i])
+		cR[i] = new(bn256.G1).ScalarMult(c[i], rPowers[i])
+		v1R[i] = new(bn256.G2).ScalarMult(v1[i], rInvPowers[i])
+		v2R[i] = new(bn256.G2).ScalarMult(v2[i], rInvPowers[i])
+		ones[i] = big.NewInt(1)
+	})
+	proof.ZAB = pairingProduct(aR, b)
+	proof.ZC = msmG1(cR, ones)
+	t.append(proof.ZAB.Marshal(), proof.ZC.Marshal())
+
+	challenges := proveRounds(proof, t, aR, b, cR, ones, v1R, v2R, w1, w2)
+	z := t.challenge()
+
+	// KZG openings of the final commitment keys at z, where the v key is the
+	// polynomial fv(X) = prod_j(1 + x_j^-1·r^-m_j·X^m_j) and the w key is
+	// fw(X) = X^n·prod_j(1 + x_j·X^m_j), being m_j = n/2^(j+1)
+	dv := make([]*big.Int, len(challenges))
+	dw := make([]*big.Int, len(challenges))
+	for j, x := range challenges {
+		m := big.NewInt(int64(n >> uint(j+1)))
+		dv[j] = new(big.Int).Exp(rInv, m, types.R)
+		dv[j].Mul(dv[j], inverse(x))
+		dv[j].Mod(dv[j], types.R)
+		dw[j] = x
+	}
+	qv := quotient(foldedCoefficients(dv, n), z)
+	fw := make([]*big.Int, n, 2*n)
+	for i := range fw {
+		fw[i] = big.NewInt(0)
+	}
+	fw = append(fw, foldedCoefficients(dw, n)...)
+	qw := quotient(fw, z)
+	proof.VKeyOpening = [2]*bn256.G2{msmG2(srs.HAlpha[:n-1], qv), msmG2(srs.HBeta[:n-1], qv)}
+	proof.WKeyOpening = [2]*bn256.G1{msmG1(srs.GAlpha[:2*n-1], qw),
+		msmG1(srs.GBeta[:2*n-1], qw)}
+	return proof, nil
+}
+
+// proveRounds runs the rounds of the TIPP and MIPP arguments, halving the
+// vectors on each round, and returns their challenges. ZC is the inner product
+// of c and the scalars r.
+func proveRounds(proof *Proof, t *transcript, a []*bn256.G1, b []*bn256.G2, c []*bn256.G1,
+	r []*big.Int, v1, v2 []*bn256.G2, w1, w2 []*bn256.G1) []*big.Int {
+	var challenges []*big.Int
+	for len(a) > 1 {
+		m := len(a) / 2 //nolint:gomnd
+		round := Round{
+			ZABL: pairingProduct(a[m:], b[:m]),
+			ZABR: pairingProduct(a[:m], b[m:]),
+			ZCL:  msmG1(c[m:], r[:m]),
+			ZCR:  msmG1(c[:m], r[m:]),
+			ComAB: [2]Commitment{
+				commitPair(v1[:m], v2[:m], w1[m:], w2[m:], a[m:], b[:m]),
+				commitPair(v1[m:], v2[m:], w1[:m], w2[:m], a[:m], b[m:]),
+			},
+			ComC: [2]Commitment{
+				commitSingle(v1[:m], v2[:m], c[m:]),
+				commitSingle(v1[m:], v2[m:], c[:m]),
+			},
+		}
+		proof.Rounds = append(proof.Rounds, round)
+		t.append(round.marshal())
+		x := t.challenge()
+		xInv := inverse(x)
+		challenges = append(challenges, x)
+
+		a, c, w1, w2 = foldG1(a, x), foldG1(c, x), foldG1(w1, x), foldG1(w2, x)
+		b, v1, v2 = foldG2(b, xInv), foldG2(v1, xInv), foldG2(v2, xInv)
+		r = foldScalars(r, xInv)
+	}
+	proof.A, proof.B, proof.C = a[0], b[0], c[0]
+	proof.VKey = [2]*bn256.G2{v1[0], v2[0]}
+	proof.WKey = [2]*bn256.G1{w1[0], w2[0]}
+	proof.appendFinal(t)
+	return challenges
+}
+
+// commitPair returns the commitment of the vectors a and b, being
+// T = prod(e(a_i, v1_i)·e(w1_i, b_i)) and U = prod(e(a_i, v2_i)·e(w2_i, b_i))
+func commitPair(v1, v2 []*bn256.G2, w1, w2, a []*bn256.G1, b []*bn256.G2) Commitment {
+	return Commitment{
+		T: pairingProduct(concatG1(a, w1), concatG2(v1, b)),
+		U: pairingProduct(concatG1(a, w2), concatG2(v2, b)),
+	}
+}
+
+// commitSingle returns the commitment of the vector c, being
+// T = prod(e(c_i, v1_i)) and U = prod(e(c_i, v2_i))
+func commitSingle(v1, v2 []*bn256.G2, c []*bn256.G1) Commitment {
+	return Commitment{T: pairingProduct(c, v1), U: pairingProduct(c, v2)}
+}
+
+// checkStatement checks the verification key and the public inputs, returning
+// the number of proofs padded to a power of two, which must not be greater
+// than the SRS size
+func checkStatement(srsN int, vk *types.Vk, inputs [][]*big.Int) (int, error) {
+	if err := verifier.ValidateVk(vk); err != nil {
+		return 0, err
+	}
+	if len(inputs) == 0 {
+		return 0, fmt.Errorf("no proofs to aggregate")
+	}
+	for i := range inputs {
+		if len(inputs[i]) != len(vk.IC)-1 {
+			return 0, fmt.Errorf("%w: proof %d has %d public inputs, expected %d",
+				verifier.ErrInputCount, i, len(inputs[i]), len(vk.IC)-1)
+		}
+		for _, v := range inputs[i] {
+			if v == nil || v.Sign() < 0 || v.Cmp(types.R) >= 0 {
+				return 0, fmt.Errorf("%w: proof %d", verifier.ErrInputOutOfField, i)
+			}
+		}
+	}
+	n := 2 //nolint:gomnd
+	for n < len(inputs) {
+		n *= 2
+	}
+	if n > srsN {
+		return 0, fmt.Errorf("%d proofs, padded to %d, do not fit in the SRS size %d",
+			len(inputs), n, srsN)
+	}
+	return n, nil
+}
+
+// padInputs repeats the last public inputs up to n
+func padInputs(inputs [][]*big.Int, n int) [][]*big.Int {
+	padded := make([][]*big.Int, n)
+	copy(padded, inputs)
+	for i := len(inputs); i < n; i++ {
+		padded[i] = inputs[len(inputs)-1]
+	}
+	return padded
+}
@@ -0,0 +1,161 @@
+package aggregation
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil/prooftest"
+	"github.com/vocdoni/go-snark/types"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+func TestAggregate(t *testing.T) {
+	vk, proofs, inputs := prooftest.CubicProofs(t, 8)
+	srs, err := GenerateSRS(8, nil)
+	require.Nil(t, err)
+	vsrs := srs.VerifierSRS()
+
+	for _, n := range []int{1, 2, 3, 8} {
+		proof, err := Aggregate(srs, vk, proofs[:n], inputs[:n])
+		require.Nil(t, err)
+		assert.Nil(t, CheckAggregate(vsrs, vk, proof, inputs[:n]), fmt.Sprintf("n: %d", n))
+		assert.True(t, Verify(vsrs, vk, proof, inputs[:n]))
+	}
+
+	proof, err := Aggregate(srs, vk, proofs, inputs)
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(proof.Rounds))
+
+	// wrong public inputs, which are part of the transcript
+	badInputs := append([][]*big.Int{}, inputs...)
+	badInputs[2] = []*big.Int{big.NewInt(1)}
+	assert.False(t, Verify(vsrs, vk, proof, badInputs))
+	assert.False(t, Verify(vsrs, vk, proof, inputs[:7]))
+	err = CheckAggregate(vsrs, vk, proof, inputs[:4])
+	assert.True(t, errors.Is(err, ErrInvalidAggregate), err)
+	err = CheckAggregate(vsrs, vk, proof, append(inputs[:7:7], []*big.Int{types.R}))
+	assert.True(t, errors.Is(err, verifier.ErrInputOutOfField), err)
+
+	// an invalid proof makes the aggregate proof invalid
+	badProofs := append([]*types.Proof{}, proofs...)
+	badProofs[5] = &types.Proof{A: proofs[5].A, B: proofs[5].B, C: proofs[6].C}
+	badProof, err := Aggregate(srs, vk, badProofs, inputs)
+	require.Nil(t, err)
+	assert.True(t, errors.Is(CheckAggregate(vsrs, vk, badProof, inputs), ErrPairing))
+
+	// the aggregate proof of another SRS
+	srs2, err := GenerateSRS(8, nil)
+	require.Nil(t, err)
+	proof2, err := Aggregate(srs2, vk, proofs, inputs)
+	require.Nil(t, err)
+	err = CheckAggregate(vsrs, vk, proof2, inputs)
+	assert.True(t, errors.Is(err, ErrKeyOpening), err)
+
+	// tampered values of the proof
+	tampered := *proof
+	tampered.Rounds = append([]Round{}, proof.Rounds...)
+	tampered.Rounds[1].ZABL = proof.Rounds[1].ZABR
+	err = CheckAggregate(vsrs, vk, &tampered, inputs)
+	assert.True(t, errors.Is(err, ErrInnerProduct), err)
+	tampered = *proof
+	tampered.C = proof.A
+	err = CheckAggregate(vsrs, vk, &tampered, inputs)
+	assert.True(t, errors.Is(err, ErrInnerProduct), err)
+	tampered = *proof
+	tampered.ZAB = proof.ComAB.T
+	err = CheckAggregate(vsrs, vk, &tampered, inputs)
+	assert.True(t, errors.Is(err, ErrInnerProduct), err)
+	tampered = *proof
+	tampered.Rounds = nil
+	err = CheckAggregate(vsrs, vk, &tampered, inputs)
+	assert.True(t, errors.Is(err, ErrInvalidAggregate), err)
+
+	_, err = Aggregate(srs, vk, append(proofs, proofs[0]), append(inputs, inputs[0]))
+	assert.NotNil(t, err)
+	_, err = Aggregate(srs, vk, proofs, inputs[:7])
+	assert.NotNil(t, err)
+	_, err = Aggregate(srs, vk, nil, nil)
+	assert.NotNil(t, err)
+	_, err = GenerateSRS(6, nil)
+	assert.NotNil(t, err)
+}
+
+func TestFoldedPolynomials(t *testing.T) {
+	d := []*big.Int{big.NewInt(3), big.NewInt(5), big.NewInt(7)}
+	coefs := foldedCoefficients(d, 8)
+	// (1 + 3X^4)(1 + 5X^2)(1 + 7X)
+	expected := []int64{1, 7, 5, 35, 3, 21, 15, 105}
+	for i := range expected {
+		assert.Equal(t, big.NewInt(expected[i]), coefs[i])
+	}
+	x := big.NewInt(11)
+	eval := big.NewInt(0)
+	for i := len(coefs) - 1; i >= 0; i-- {
+		eval.Mul(eval, x)
+		eval.Add(eval, coefs[i])
+	}
+	assert.Equal(t, eval.Mod(eval, types.R), evalFolded(d, 8, x))
+
+	// (f(X) - f(z)) = q(X)·(X - z)
+	q := quotient(coefs, x)
+	fz := evalFolded(d, 8, x)
+	y := big.NewInt(13)
+	fy, qy := evalFolded(d, 8, y), big.NewInt(0)
+	for i := len(q) - 1; i >= 0; i-- {
+		qy.Mul(qy, y)
+		qy.Add(qy, q[i])
+	}
+	qy.Mul(qy, new(big.Int).Sub(y, x))
+	assert.Equal(t, new(big.Int).Mod(new(big.Int).Sub(fy, fz), types.R), qy.Mod(qy, types.R))
+}
+
+func BenchmarkAggregate(b *testing.B) {
+	vk, proofs, inputs := prooftest.CubicProofs(b, 64)
+	srs, err := GenerateSRS(64, nil)
+	require.Nil(b, err)
+	vsrs := srs.VerifierSRS()
+	proof, err := Aggregate(srs, vk, proofs, inputs)
+	require.Nil(b, err)
+	b.Run("Aggregate", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = Aggregate(srs, vk, proofs, inputs)
+		}
+	})
+	b.Run("Verify", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Verify(vsrs, vk, proof, inputs)
+		}
+	})
+}
+
+func TestProofMarshal(t *testing.T) {
+	vk, proofs, inputs := prooftest.CubicProofs(t, 4)
+	srs, err := GenerateSRS(4, nil)
+	require.Nil(t, err)
+	proof, err := Aggregate(srs, vk, proofs, inputs)
+	require.Nil(t, err)
+
+	b := proof.Marshal()
+	assert.Equal(t, sizeFixed+2*sizeRound, len(b))
+	var decoded Proof
+	require.Nil(t, decoded.Unmarshal(b))
+	assert.Equal(t, b, decoded.Marshal())
+	assert.True(t, Verify(srs.VerifierSRS(), vk, &decoded, inputs))
+
+	assert.True(t, errors.Is(decoded.Unmarshal(b[:len(b)-1]), ErrInvalidAggregate))
+	assert.True(t, errors.Is(decoded.Unmarshal(nil), ErrInvalidAggregate))
+	bad := append([]byte{}, b...)
+	bad[4+2*sizeGT+2*sizeGT+sizeGT]++
+	assert.True(t, errors.Is(decoded.Unmarshal(bad), ErrInvalidAggregate))
+
+	// the Miller loop without the final exponentiation is not in GT
+	bad = append([]byte{}, b...)
+	copy(bad[4:], bn256.Miller(proofs[0].A, proofs[0].B).Marshal())
+	err = decoded.Unmarshal(bad)
+	assert.True(t, errors.Is(err, ErrInvalidAggregate), err)
+}
@@ -0,0 +1,196 @@
+package aggregation
+
+import (
+	"bytes"
+	"math/big"
+	"runtime"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/internal/parallel"
+	"github.com/vocdoni/go-snark/types"
+)
+
+var (
+	zeroG1 = new(bn256.G1).ScalarBaseMult(big.NewInt(0)).Marshal()
+	zeroG2 = new(bn256.G2).ScalarBaseMult(big.NewInt(0)).Marshal()
+	// gtOne is the identity of GT, as the pairing with the identity is one
+	gtOne = bn256.Pair(new(bn256.G1).ScalarBaseMult(big.NewInt(0)),
+		new(bn256.G2).ScalarBaseMult(big.NewInt(1)))
+)
+
+// pairingProduct returns prod(e(a[i], b[i])), computing the Miller loops in
+// parallel and a single final exponentiation. The pairs with the identity are
+// skipped, as their pairing is one.
+func pairingProduct(a []*bn256.G1, b []*bn256.G2) *bn256.GT {
+	// the points are marshaled before the Miller loops, as marshaling makes
+	// them affine, so they are not modified concurrently
+	skip := make([]bool, len(a))
+	for i := range a {
+		skip[i] = bytes.Equal(a[i].Marshal(), zeroG1) || bytes.Equal(b[i].Marshal(), zeroG2)
+	}
+	parts := make([]*bn256.GT, runtime.NumCPU())
+	parallel.Ranges(len(a), func(part, start, end int) {
+		acc := new(bn256.GT).Set(gtOne)
+		for i := start; i < end; i++ {
+			if !skip[i] {
+				acc = new(bn256.GT).Add(acc, bn256.Miller(a[i], b[i]))
+			}
+		}
+		parts[part] = acc
+	})
+	acc := new(bn256.GT).Set(gtOne)
+	for _, p := range parts {
+		if p != nil {
+			acc = new(bn256.GT).Add(acc, p)
+		}
+	}
+	return acc.Finalize()
+}
+
+// msmG1 returns sum(k[i]·a[i])
+func msmG1(a []*bn256.G1, k []*big.Int) *bn256.G1 {
+	parts := make([]*bn256.G1, runtime.NumCPU())
+	parallel.Ranges(len(a), func(part, start, end int) {
+		acc := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		for i := start; i < end; i++ {
+			acc = new(bn256.G1).Add(acc, new(bn256.G1).ScalarMult(a[i], k[i]))
+		}
+		parts[part] = acc
+	})
+	acc := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	for _, p := range parts {
+		if p != nil {
+			acc = new(bn256.G1).Add(acc, p)
+		}
+	}
+	return acc
+}
+
+// msmG2 returns sum(k[i]·a[i])
+func msmG2(a []*bn256.G2, k []*big.Int) *bn256.G2 {
+	parts := make([]*bn256.G2, runtime.NumCPU())
+	parallel.Ranges(len(a), func(part, start, end int) {
+		acc := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+		for i := start; i < end; i++ {
+			acc = new(bn256.G2).Add(acc, new(bn256.G2).ScalarMult(a[i], k[i]))
+		}
+		parts[part] = acc
+	})
+	acc := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+	for _, p := range parts {
+		if p != nil {
+			acc = new(bn256.G2).Add(acc, p)
+		}
+	}
+	return acc
+}
+
+// foldG1 returns the vector a[:m] + x·a[m:], where m is the half of len(a)
+func foldG1(a []*bn256.G1, x *big.Int) []*bn256.G1 {
+	m := len(a) / 2 //nolint:gomnd
+	r := make([]*bn256.G1, m)
+	parallel.For(m, func(i int) {
+		r[i] = new(bn256.G1).Add(a[i], new(bn256.G1).ScalarMult(a[m+i], x))
+	})
+	return r
+}
+
+// foldG2 returns the vector a[:m] + x·a[m:], where m is the half of len(a)
+func foldG2(a []*bn256.G2, x *big.Int) []*bn256.G2 {
+	m := len(a) / 2 //nolint:gomnd
+	r := make([]*bn256.G2, m)
+	parallel.For(m, func(i int) {
+		r[i] = new(bn256.G2).Add(a[i], new(bn256.G2).ScalarMult(a[m+i], x))
+	})
+	return r
+}
+
+// foldScalars returns the vector a[:m] + x·a[m:] in the field, where m is the
+// half of len(a)
+func foldScalars(a []*big.Int, x *big.Int) []*big.Int {
+	m := len(a) / 2 //nolint:gomnd
+	r := make([]*big.Int, m)
+	for i := range r {
+		r[i] = new(big.Int).Mul(a[m+i], x)
+		r[i].Add(r[i], a[i])
+		r[i].Mod(r[i], types.R)
+	}
+	return r
+}
+
+func concatG1(a, b []*bn256.G1) []*bn256.G1 {
+	return append(append(make([]*bn256.G1, 0, len(a)+len(b)), a...), b...)
+}
+
+func concatG2(a, b []*bn256.G2) []*bn256.G2 {
+	return append(append(make([]*bn256.G2, 0, len(a)+len(b)), a...), b...)
+}
+
+// gtEqual reports whether the elements of GT are equal
+func gtEqual(a, b *bn256.GT) bool {
+	return bytes.Equal(a.Marshal(), b.Marshal())
+}
+
+// gtFold returns t·l^x·r^xInv
+func gtFold(t, l, r *bn256.GT, x, xInv *big.Int) *bn256.GT {
+	res := new(bn256.GT).Add(t, new(bn256.GT).ScalarMult(l, x))
+	return new(bn256.GT).Add(res, new(bn256.GT).ScalarMult(r, xInv))
+}
+
+// foldedCoefficients returns the coefficients of the polynomial
+// prod_j(1 + d[j]·X^(n/2^(j+1))), of degree n-1, where the coefficient i is the
+// product of the d[j] of the bits set in i
+func foldedCoefficients(d []*big.Int, n int) []*big.Int {
+	coefs := make([]*big.Int, n)
+	coefs[0] = big.NewInt(1)
+	for i := 1; i < n; i++ {
+		// j is the round of the highest bit of i
+		hb, j := n/2, 0 //nolint:gomnd
+		for i&hb == 0 {
+			hb, j = hb/2, j+1 //nolint:gomnd
+		}
+		coefs[i] = new(big.Int).Mul(coefs[i-hb], d[j])
+		coefs[i].Mod(coefs[i], types.R)
+	}
+	return coefs
+}
+
+// evalFolded evaluates prod_j(1 + d[j]·x^(n/2^(j+1)))
+func evalFolded(d []*big.Int, n int, x *big.Int) *big.Int {
+	res := big.NewInt(1)
+	for j := range d {
+		m := big.NewInt(int64(n >> uint(j+1)))
+		t := new(big.Int).Exp(x, m, types.R)
+		t.Mul(t, d[j])
+		t.Add(t, big.NewInt(1))
+		res.Mul(res, t)
+		res.Mod(res, types.R)
+	}
+	return res
+}
+
+// quotient returns the coefficients of (f(X) - f(z)) / (X - z)
+func quotient(f []*big.Int, z *big.Int) []*big.Int {
+	q := make([]*big.Int, len(f)-1)
+	acc := big.NewInt(0)
+	for i := len(f) - 1; i > 0; i-- {
+		acc = new(big.Int).Mul(acc, z)
+		acc.Add(acc, f[i])
+		acc.Mod(acc, types.R)
+		q[i-1] = acc
+	}
+	return q
+}
+
+func inverse(x *big.Int) *big.Int {
+	return new(big.Int).ModInverse(x, types.R)
+}
+
+// log2 returns the base 2 logarithm of the power of two n
+func log2(n int) int {
+	l := 0
+	for ; n > 1; n /= 2 {
+		l++
+	}
+	return l
+}
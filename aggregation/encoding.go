@@ -0,0 +1,136 @@
+package aggregation
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+)
+
+const (
+	sizeG1 = 64
+	sizeG2 = 128
+	sizeGT = 384
+	// sizeRound is the size of the 10 GT and 2 G1 elements of a round
+	sizeRound = 10*sizeGT + 2*sizeG1
+	// sizeFixed is the size of the header and the values that do not depend
+	// on the number of rounds
+	sizeFixed = 4 + 5*sizeGT + 7*sizeG1 + 5*sizeG2
+	// maxRounds is the maximum number of rounds decoded, of 2^32 proofs
+	maxRounds = 32
+)
+
+// Marshal encodes the aggregate proof as the uint32 little-endian number of
+// rounds followed by ComAB, ComC, ZAB, ZC, the rounds, A, B, C, VKey, WKey,
+// VKeyOpening and WKeyOpening, being each round ZABL, ZABR, ZCL, ZCR, ComAB
+// and ComC. The elements of G1 and G2 are encoded as by bn256 and the
+// commitments as T followed by U.
+func (p *Proof) Marshal() []byte {
+	b := make([]byte, 4, sizeFixed+len(p.Rounds)*sizeRound) //nolint:gomnd
+	binary.LittleEndian.PutUint32(b, uint32(len(p.Rounds)))
+	b = append(b, p.ComAB.marshal()...)
+	b = append(b, p.ComC.marshal()...)
+	b = append(b, p.ZAB.Marshal()...)
+	b = append(b, p.ZC.Marshal()...)
+	for i := range p.Rounds {
+		b = append(b, p.Rounds[i].marshal()...)
+	}
+	for _, e := range [][]byte{p.A.Marshal(), p.B.Marshal(), p.C.Marshal(),
+		p.VKey[0].Marshal(), p.VKey[1].Marshal(), p.WKey[0].Marshal(), p.WKey[1].Marshal(),
+		p.VKeyOpening[0].Marshal(), p.VKeyOpening[1].Marshal(),
+		p.WKeyOpening[0].Marshal(), p.WKeyOpening[1].Marshal()} {
+		b = append(b, e...)
+	}
+	return b
+}
+
+// Unmarshal decodes the aggregate proof encoded by Marshal, checking that the
+// points are on the curves and the elements of GT in the subgroup of order R
+func (p *Proof) Unmarshal(b []byte) error {
+	if len(b) < 4 { //nolint:gomnd
+		return fmt.Errorf("%w: not enough data", ErrInvalidAggregate)
+	}
+	nRounds := int(binary.LittleEndian.Uint32(b))
+	if nRounds > maxRounds || len(b) != sizeFixed+nRounds*sizeRound {
+		return fmt.Errorf("%w: %d bytes do not match %d rounds", ErrInvalidAggregate,
+			len(b), nRounds)
+	}
+	d := &decoder{b: b[4:]}
+	q := &Proof{
+		ComAB: d.commitment(),
+		ComC:  d.commitment(),
+		ZAB:   d.gt(),
+		ZC:    d.g1(),
+	}
+	q.Rounds = make([]Round, nRounds)
+	for i := range q.Rounds {
+		q.Rounds[i] = Round{
+			ZABL:  d.gt(),
+			ZABR:  d.gt(),
+			ZCL:   d.g1(),
+			ZCR:   d.g1(),
+			ComAB: [2]Commitment{d.commitment(), d.commitment()},
+			ComC:  [2]Commitment{d.commitment(), d.commitment()},
+		}
+	}
+	q.A, q.B, q.C = d.g1(), d.g2(), d.g1()
+	q.VKey = [2]*bn256.G2{d.g2(), d.g2()}
+	q.WKey = [2]*bn256.G1{d.g1(), d.g1()}
+	q.VKeyOpening = [2]*bn256.G2{d.g2(), d.g2()}
+	q.WKeyOpening = [2]*bn256.G1{d.g1(), d.g1()}
+	if d.err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAggregate, d.err)
+	}
+	*p = *q
+	return nil
+}
+
+// decoder decodes consecutive elements, keeping the first error
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	e := d.b[:n]
+	d.b = d.b[n:]
+	return e
+}
+
+func (d *decoder) g1() *bn256.G1 {
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(d.next(sizeG1)); err != nil && d.err == nil {
+		d.err = err
+	}
+	return p
+}
+
+func (d *decoder) g2() *bn256.G2 {
+	p := new(bn256.G2)
+	if _, err := p.Unmarshal(d.next(sizeG2)); err != nil && d.err == nil {
+		d.err = err
+	}
+	return p
+}
+
+// gt decodes an element of GT, checking that it is in the subgroup of order
+// R, as the elements of Fp12 out of it could break the soundness of the
+// verification
+func (d *decoder) gt() *bn256.GT {
+	e := new(bn256.GT)
+	if _, err := e.Unmarshal(d.next(sizeGT)); err != nil {
+		if d.err == nil {
+			d.err = err
+		}
+		return e
+	}
+	if d.err == nil && !gtEqual(new(bn256.GT).ScalarMult(e, types.R), gtOne) {
+		d.err = fmt.Errorf("element of GT not in the subgroup of order R")
+	}
+	return e
+}
+
+func (d *decoder) commitment() Commitment {
+	return Commitment{T: d.gt(), U: d.gt()}
+}
@@ -0,0 +1,114 @@
+package aggregation
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/internal/parallel"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// SRS is the structured reference string of the aggregation, with the powers
+// g^(a^i) and g^(b^i) for i in [0, 2N), and h^(a^i) and h^(b^i) for i in
+// [0, N), where g and h are the generators of G1 and G2 and a and b are
+// secret. It can aggregate up to N proofs.
+type SRS struct {
+	N      int
+	GAlpha []*bn256.G1
+	GBeta  []*bn256.G1
+	HAlpha []*bn256.G2
+	HBeta  []*bn256.G2
+}
+
+// VerifierSRS is the part of the SRS used to verify the aggregate proofs
+type VerifierSRS struct {
+	N      int
+	GAlpha *bn256.G1
+	GBeta  *bn256.G1
+	HAlpha *bn256.G2
+	HBeta  *bn256.G2
+}
+
+// GenerateSRS generates a SRS for up to n proofs, where n must be a power of
+// two, drawing the secrets a and b from rnd (crypto/rand.Reader if nil). As
+// whoever knows a or b can forge aggregate proofs, it must only be used for
+// tests, the SRS of production deployments must come from a trusted setup
+// ceremony.
+func GenerateSRS(n int, rnd io.Reader) (*SRS, error) {
+	if n < 2 || n&(n-1) != 0 {
+		return nil, fmt.Errorf("SRS size must be a power of two greater than 1, got %d", n)
+	}
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	a, err := randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+	b, err := randScalar(rnd)
+	if err != nil {
+		return nil, err
+	}
+	aPowers := powers(a, 2*n)
+	bPowers := powers(b, 2*n)
+	srs := &SRS{
+		N:      n,
+		GAlpha: make([]*bn256.G1, 2*n),
+		GBeta:  make([]*bn256.G1, 2*n),
+		HAlpha: make([]*bn256.G2, n),
+		HBeta:  make([]*bn256.G2, n),
+	}
+	// the points are marshaled once, making them affine, so they are not
+	// modified when they are marshaled by concurrent aggregations
+	parallel.For(2*n, func(i int) {
+		srs.GAlpha[i] = new(bn256.G1).ScalarBaseMult(aPowers[i])
+		srs.GBeta[i] = new(bn256.G1).ScalarBaseMult(bPowers[i])
+		srs.GAlpha[i].Marshal()
+		srs.GBeta[i].Marshal()
+		if i < n {
+			srs.HAlpha[i] = new(bn256.G2).ScalarBaseMult(aPowers[i])
+			srs.HBeta[i] = new(bn256.G2).ScalarBaseMult(bPowers[i])
+			srs.HAlpha[i].Marshal()
+			srs.HBeta[i].Marshal()
+		}
+	})
+	return srs, nil
+}
+
+// VerifierSRS returns the part of the SRS used to verify the aggregate proofs
+func (srs *SRS) VerifierSRS() *VerifierSRS {
+	return &VerifierSRS{
+		N:      srs.N,
+		GAlpha: srs.GAlpha[1],
+		GBeta:  srs.GBeta[1],
+		HAlpha: srs.HAlpha[1],
+		HBeta:  srs.HBeta[1],
+	}
+}
+
+// randScalar returns a random non-zero element of the field
+func randScalar(rnd io.Reader) (*big.Int, error) {
+	for {
+		k, err := rand.Int(rnd, types.R)
+		if err != nil {
+			return nil, err
+		}
+		if k.Sign() != 0 {
+			return k, nil
+		}
+	}
+}
+
+// powers returns [1, x, x^2, ..., x^(n-1)] in the field
+func powers(x *big.Int, n int) []*big.Int {
+	p := make([]*big.Int, n)
+	p[0] = big.NewInt(1)
+	for i := 1; i < n; i++ {
+		p[i] = new(big.Int).Mul(p[i-1], x)
+		p[i].Mod(p[i], types.R)
+	}
+	return p
+}
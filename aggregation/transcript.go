@@ -0,0 +1,65 @@
+package aggregation
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"math/big"
+
+	"github.com/vocdoni/go-snark/types"
+)
+
+// transcriptLabel is the domain separator of the Fiat-Shamir transcript
+const transcriptLabel = "go-snark/aggregation/v1"
+
+// transcript derives the challenges of the aggregation from the hash of all
+// the values sent before them
+type transcript struct {
+	h hash.Hash
+}
+
+func newTranscript() *transcript {
+	t := &transcript{h: sha256.New()}
+	t.append([]byte(transcriptLabel))
+	return t
+}
+
+func (t *transcript) append(b ...[]byte) {
+	for _, v := range b {
+		_, _ = t.h.Write(v)
+	}
+}
+
+// appendStatement appends the number of proofs, the verification key and
+// the public inputs
+func (t *transcript) appendStatement(n int, vk *types.Vk, inputs [][]*big.Int) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(n))
+	t.append(b[:], vk.Alpha.Marshal(), vk.Beta.Marshal(), vk.Gamma.Marshal(),
+		vk.Delta.Marshal())
+	for _, p := range vk.IC {
+		t.append(p.Marshal())
+	}
+	var s [32]byte
+	for i := range inputs {
+		for _, v := range inputs[i] {
+			v.FillBytes(s[:])
+			t.append(s[:])
+		}
+	}
+}
+
+// challenge returns a non-zero element of the field from the hash of the
+// transcript, which is restarted from the hash
+func (t *transcript) challenge() *big.Int {
+	for {
+		sum := t.h.Sum(nil)
+		t.h.Reset()
+		t.append(sum)
+		c := new(big.Int).SetBytes(sum)
+		c.Mod(c, types.R)
+		if c.Sign() != 0 {
+			return c
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package aggregation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+)
+
+var (
+	// ErrInvalidAggregate is returned when the aggregate proof is malformed,
+	// or it does not match the number of proofs
+	ErrInvalidAggregate = errors.New("invalid aggregate proof")
+	// ErrInnerProduct is returned when the final values of the TIPP and MIPP
+	// arguments do not match their commitments
+	ErrInnerProduct = errors.New("inner product arguments verification failed")
+	// ErrKeyOpening is returned when the final commitment keys are not the
+	// ones derived from the SRS
+	ErrKeyOpening = errors.New("commitment keys opening verification failed")
+	// ErrPairing is returned when the aggregated Groth16 pairing check fails
+	ErrPairing = errors.New("aggregated pairing check failed")
+)
+
+// Verify verifies the aggregate proof of the Groth16 proofs of the
+// verification key, where inputs[i] are the public inputs of the proof i
+func Verify(vsrs *VerifierSRS, vk *types.Vk, proof *Proof, inputs [][]*big.Int) bool {
+	return CheckAggregate(vsrs, vk, proof, inputs) == nil
+}
+
+// CheckAggregate verifies the aggregate proof of the Groth16 proofs of the
+// verification key, where inputs[i] are the public inputs of the proof i,
+// returning the reason of the failure: an error of the verification key or
+// the inputs, as the ones of the verifier package, or ErrInvalidAggregate,
+// ErrInnerProduct, ErrKeyOpening or ErrPairing.
+func CheckAggregate(vsrs *VerifierSRS, vk *types.Vk, proof *Proof, inputs [][]*big.Int) error {
+	n, err := checkStatement(vsrs.N, vk, inputs)
+	if err != nil {
+		return err
+	}
+	if err := checkShape(proof, n); err != nil {
+		return err
+	}
+	inputs = padInputs(inputs, n)
+
+	t := newTranscript()
+	t.appendStatement(n, vk, inputs)
+	t.append(proof.ComAB.marshal(), proof.ComC.marshal())
+	r := t.challenge()
+	t.append(proof.ZAB.Marshal(), proof.ZC.Marshal())
+
+	// fold the commitments and the inner products with the challenges of the
+	// rounds
+	comAB, comC, zAB, zC := proof.ComAB, proof.ComC, proof.ZAB, proof.ZC
+	challenges := make([]*big.Int, len(proof.Rounds))
+	for j := range proof.Rounds {
+		round := &proof.Rounds[j]
+		t.append(round.marshal())
+		x := t.challenge()
+		xInv := inverse(x)
+		challenges[j] = x
+
+		comAB = Commitment{
+			T: gtFold(comAB.T, round.ComAB[0].T, round.ComAB[1].T, x, xInv),
+			U: gtFold(comAB.U, round.ComAB[0].U, round.ComAB[1].U, x, xInv),
+		}
+		comC = Commitment{
+			T: gtFold(comC.T, round.ComC[0].T, round.ComC[1].T, x, xInv),
+			U: gtFold(comC.U, round.ComC[0].U, round.ComC[1].U, x, xInv),
+		}
+		zAB = gtFold(zAB, round.ZABL, round.ZABR, x, xInv)
+		zC = new(bn256.G1).Add(zC, new(bn256.G1).ScalarMult(round.ZCL, x))
+		zC = new(bn256.G1).Add(zC, new(bn256.G1).ScalarMult(round.ZCR, xInv))
+	}
+	proof.appendFinal(t)
+	z := t.challenge()
+
+	if err := checkFinal(proof, n, challenges, comAB, comC, zAB, zC); err != nil {
+		return err
+	}
+	if err := checkKeyOpenings(vsrs, proof, n, r, challenges, z); err != nil {
+		return err
+	}
+	return checkPairing(vk, inputs, r, proof.ZAB, proof.ZC)
+}
+
+// checkShape checks that the aggregate proof has all its values, and the
+// number of rounds of n proofs
+func checkShape(proof *Proof, n int) error {
+	if proof == nil {
+		return fmt.Errorf("%w: nil proof", ErrInvalidAggregate)
+	}
+	if len(proof.Rounds) != log2(n) {
+		return fmt.Errorf("%w: got %d rounds, expected %d for %d proofs",
+			ErrInvalidAggregate, len(proof.Rounds), log2(n), n)
+	}
+	gt := []*bn256.GT{proof.ComAB.T, proof.ComAB.U, proof.ComC.T, proof.ComC.U, proof.ZAB}
+	for _, round := range proof.Rounds {
+		gt = append(gt, round.ZABL, round.ZABR)
+		for _, c := range []Commitment{round.ComAB[0], round.ComAB[1], round.ComC[0],
+			round.ComC[1]} {
+			gt = append(gt, c.T, c.U)
+		}
+		if round.ZCL == nil || round.ZCR == nil {
+			return fmt.Errorf("%w: missing values", ErrInvalidAggregate)
+		}
+	}
+	for _, v := range gt {
+		if v == nil {
+			return fmt.Errorf("%w: missing values", ErrInvalidAggregate)
+		}
+	}
+	if proof.ZC == nil || proof.A == nil || proof.B == nil || proof.C == nil ||
+		proof.VKey[0] == nil || proof.VKey[1] == nil || proof.WKey[0] == nil ||
+		proof.WKey[1] == nil || proof.VKeyOpening[0] == nil || proof.VKeyOpening[1] == nil ||
+		proof.WKeyOpening[0] == nil || proof.WKeyOpening[1] == nil {
+		return fmt.Errorf("%w: missing values", ErrInvalidAggregate)
+	}
+	return nil
+}
+
+// checkFinal checks that the final values of the rounds match the folded
+// commitments and inner products
+func checkFinal(proof *Proof, n int, challenges []*big.Int, comAB, comC Commitment,
+	zAB *bn256.GT, zC *bn256.G1) error {
+	// ZC is the sum of the rescaled C, so the vector of ones is folded as
+	// prod_j(1 + x_j^-1)
+	dr := make([]*big.Int, len(challenges))
+	for j, x := range challenges {
+		dr[j] = inverse(x)
+	}
+	onesFinal := evalFolded(dr, n, big.NewInt(1))
+
+	if !gtEqual(comAB.T, pairingProduct([]*bn256.G1{proof.A, proof.WKey[0]},
+		[]*bn256.G2{proof.VKey[0], proof.B})) ||
+		!gtEqual(comAB.U, pairingProduct([]*bn256.G1{proof.A, proof.WKey[1]},
+			[]*bn256.G2{proof.VKey[1], proof.B})) {
+		return fmt.Errorf("%w: commitment of A and B", ErrInnerProduct)
+	}
+	if !gtEqual(comC.T, pairingProduct([]*bn256.G1{proof.C}, []*bn256.G2{proof.VKey[0]})) ||
+		!gtEqual(comC.U, pairingProduct([]*bn256.G1{proof.C}, []*bn256.G2{proof.VKey[1]})) {
+		return fmt.Errorf("%w: commitment of C", ErrInnerProduct)
+	}
+	if !gtEqual(zAB, pairingProduct([]*bn256.G1{proof.A}, []*bn256.G2{proof.B})) {
+		return fmt.Errorf("%w: pairing product of A and B", ErrInnerProduct)
+	}
+	if !bytes.Equal(new(bn256.G1).ScalarMult(proof.C, onesFinal).Marshal(), zC.Marshal()) {
+		return fmt.Errorf("%w: multi-scalar multiplication of C", ErrInnerProduct)
+	}
+	return nil
+}
+
+// checkKeyOpenings checks the KZG openings at z of the final commitment keys,
+// being the v keys the evaluations at a and b of
+// fv(X) = prod_j(1 + x_j^-1·r^-m_j·X^m_j) and the w keys the ones of
+// fw(X) = X^n·prod_j(1 + x_j·X^m_j), where m_j = n/2^(j+1)
+func checkKeyOpenings(vsrs *VerifierSRS, proof *Proof, n int, r *big.Int,
+	challenges []*big.Int, z *big.Int) error {
+	dv := make([]*big.Int, len(challenges))
+	for j, x := range challenges {
+		dv[j] = inverse(x)
+	}
+	fvz := evalFolded(dv, n, new(big.Int).Mod(new(big.Int).Mul(z, inverse(r)), types.R))
+	fwz := evalFolded(challenges, n, z)
+	fwz.Mul(fwz, new(big.Int).Exp(z, big.NewInt(int64(n)), types.R))
+	fwz.Mod(fwz, types.R)
+
+	negZ := new(big.Int).Sub(types.R, z)
+	negG := new(bn256.G1).ScalarBaseMult(new(big.Int).Sub(types.R, big.NewInt(1)))
+	h := new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+
+	// e(g^(a-z), opening) == e(g, v - fv(z)·h)
+	negFvzH := new(bn256.G2).ScalarBaseMult(new(big.Int).Sub(types.R, fvz))
+	for i, gs := range []*bn256.G1{vsrs.GAlpha, vsrs.GBeta} {
+		gsz := new(bn256.G1).Add(gs, new(bn256.G1).ScalarBaseMult(negZ))
+		if !bn256.PairingCheck([]*bn256.G1{gsz, negG},
+			[]*bn256.G2{proof.VKeyOpening[i], new(bn256.G2).Add(proof.VKey[i], negFvzH)}) {
+			return fmt.Errorf("%w: v key %d", ErrKeyOpening, i)
+		}
+	}
+	// e(opening, h^(a-z)) == e(w - fw(z)·g, h)
+	fwzG := new(bn256.G1).ScalarBaseMult(fwz)
+	for i, hs := range []*bn256.G2{vsrs.HAlpha, vsrs.HBeta} {
+		hsz := new(bn256.G2).Add(hs, new(bn256.G2).ScalarBaseMult(negZ))
+		negW := new(bn256.G1).Add(fwzG, new(bn256.G1).Neg(proof.WKey[i]))
+		if !bn256.PairingCheck([]*bn256.G1{proof.WKeyOpening[i], negW},
+			[]*bn256.G2{hsz, h}) {
+			return fmt.Errorf("%w: w key %d", ErrKeyOpening, i)
+		}
+	}
+	return nil
+}
+
+// checkPairing checks the random linear combination of the Groth16 pairing
+// checks of the proofs, ZAB == e(alpha, beta)^sum(r^i) · e(sum(r^i·vkX_i),
+// gamma) · e(ZC, delta)
+func checkPairing(vk *types.Vk, inputs [][]*big.Int, r *big.Int, zAB *bn256.GT,
+	zC *bn256.G1) error {
+	rPowers := powers(r, len(inputs))
+	icScalars := make([]*big.Int, len(vk.IC))
+	for j := range icScalars {
+		icScalars[j] = big.NewInt(0)
+	}
+	for i := range inputs {
+		icScalars[0].Add(icScalars[0], rPowers[i])
+		for j, v := range inputs[i] {
+			icScalars[j+1].Add(icScalars[j+1], new(big.Int).Mul(rPowers[i], v))
+		}
+	}
+	for j := range icScalars {
+		icScalars[j].Mod(icScalars[j], types.R)
+	}
+	vkX := msmG1(vk.IC, icScalars)
+	alpha := new(bn256.G1).ScalarMult(vk.Alpha, icScalars[0])
+	if !gtEqual(zAB, pairingProduct([]*bn256.G1{alpha, vkX, zC},
+		[]*bn256.G2{vk.Beta, vk.Gamma, vk.Delta})) {
+		return ErrPairing
+	}
+	return nil
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/vocdoni/go-snark/parsers"
 	"github.com/vocdoni/go-snark/prover"
+	"github.com/vocdoni/go-snark/server"
+	"github.com/vocdoni/go-snark/solidity"
+	"github.com/vocdoni/go-snark/types"
 	"github.com/vocdoni/go-snark/verifier"
 )
 
@@ -21,32 +33,86 @@ func main() {
 
 	prove := flag.Bool("prove", false, "prover mode")
 	verify := flag.Bool("verify", false, "verifier mode")
-	convert := flag.Bool("convert", false, "convert mode, to convert between"+
-		" proving_key.json to proving_key.go.bin")
+	convert := flag.Bool("convert", false, "convert mode, to convert the proving key"+
+		" (.json, snarkjs .zkey, wasmsnark .bin or .go.bin) to proving_key.go.bin")
+	solidityMode := flag.Bool("solidity", false, "solidity mode, to generate the"+
+		" verifier contract of the verificationKey")
+	calldata := flag.Bool("calldata", false, "calldata mode, to print the call data"+
+		" of the verifier contract for the proof and public signals")
+	serve := flag.Bool("serve", false, "server mode, to verify proofs over HTTP")
+	daemon := flag.Bool("daemon", false, "prover daemon mode, to generate proofs over HTTP"+
+		" keeping the proving keys in memory")
 
-	provingKeyPath := flag.String("pk", "proving_key.json", "provingKey path")
-	witnessPath := flag.String("witness", "witness.json", "witness path")
+	provingKeyPath := flag.String("pk", "proving_key.json", "provingKey path (.json,"+
+		" snarkjs .zkey, wasmsnark .bin or .go.bin)")
+	witnessPath := flag.String("witness", "witness.json", "witness path (.json, circom .wtns"+
+		" or wasmsnark .bin)")
 	proofPath := flag.String("proof", "proof.json", "proof path")
 	verificationKeyPath := flag.String("vk", "verification_key.json", "verificationKey path")
 	publicPath := flag.String("public", "public.json", "public signals path")
 	provingKeyBinPath := flag.String("pkbin", "proving_key.go.bin", "provingKey Bin path")
+	provingKeyBinVersion := flag.Int("pkbinversion", 2, "in convert mode, version of the"+
+		" go-snark binary format (1 or 2)")
+	evm := flag.Bool("evm", false, "in verifier mode, also verify the proof with the"+
+		" EVM bn256 precompiles, printing their gas cost")
+	contractPath := flag.String("contract", "verifier.sol", "solidity verifier contract path")
+	r1csPath := flag.String("r1cs", "", "optional r1cs path, to check the witness"+
+		" before generating the proof")
+	addr := flag.String("addr", "localhost:8080", "in server and daemon modes, address to"+
+		" listen on, or unix:path for a Unix socket")
+	vksFlag := flag.String("vks", "", "in server mode, comma separated list of name=path"+
+		" verification keys, the vk flag is served as \"default\" if empty")
+	pksFlag := flag.String("pks", "", "in daemon mode, comma separated list of name=path"+
+		" proving keys (.json, snarkjs .zkey, wasmsnark .bin or .go.bin), the pk flag is served as"+
+		" \"default\" if empty")
+	workers := flag.Int("workers", 1, "in daemon mode, number of proofs generated concurrently")
+	maxQueue := flag.Int("maxqueue", 0, "in daemon mode, maximum number of queued proofs,"+
+		" the number of workers if 0")
+	maxRequestSize := flag.Int64("maxrequest", 0, "in server and daemon modes, maximum"+
+		" request size in bytes, 1MB (server) or 64MB (daemon) if 0")
+	maxConcurrent := flag.Int("maxconcurrent", 0, "in server mode, maximum number of"+
+		" concurrent verifications, the number of CPUs if 0")
 
 	flag.Parse()
 
 	if *prove {
-		err := cmdProve(*provingKeyPath, *witnessPath, *proofPath, *publicPath)
+		err := cmdProve(*provingKeyPath, *witnessPath, *proofPath, *publicPath, *r1csPath)
 		if err != nil {
 			fmt.Println("Error:", err)
 		}
 		os.Exit(0)
 	} else if *verify {
-		err := cmdVerify(*proofPath, *verificationKeyPath, *publicPath)
+		err := cmdVerify(*proofPath, *verificationKeyPath, *publicPath, *evm)
 		if err != nil {
 			fmt.Println("Error:", err)
 		}
 		os.Exit(0)
 	} else if *convert {
-		err := cmdConvert(*provingKeyPath, *provingKeyBinPath)
+		err := cmdConvert(*provingKeyPath, *provingKeyBinPath, *provingKeyBinVersion)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(0)
+	} else if *solidityMode {
+		err := cmdSolidity(*verificationKeyPath, *contractPath)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(0)
+	} else if *calldata {
+		err := cmdCalldata(*proofPath, *publicPath)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(0)
+	} else if *serve {
+		err := cmdServe(*addr, *vksFlag, *verificationKeyPath, *maxRequestSize, *maxConcurrent)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(0)
+	} else if *daemon {
+		err := cmdDaemon(*addr, *pksFlag, *provingKeyPath, *workers, *maxQueue, *maxRequestSize)
 		if err != nil {
 			fmt.Println("Error:", err)
 		}
@@ -55,32 +121,44 @@ func main() {
 	flag.PrintDefaults()
 }
 
-func cmdProve(provingKeyPath, witnessPath, proofPath, publicPath string) error {
+func cmdProve(provingKeyPath, witnessPath, proofPath, publicPath, r1csPath string) error {
 	fmt.Println("zkSNARK Groth16 prover")
 
 	fmt.Println("Reading proving key file:", provingKeyPath)
-	provingKeyJSON, err := ioutil.ReadFile(provingKeyPath) //nolint:gosec
-	if err != nil {
-		return err
-	}
-	pk, err := parsers.ParsePk(provingKeyJSON)
+	pk, err := readPk(provingKeyPath)
 	if err != nil {
 		return err
 	}
 
 	fmt.Println("Reading witness file:", witnessPath)
-	witnessJSON, err := ioutil.ReadFile(witnessPath) //nolint:gosec
+	w, err := readWitness(witnessPath, pk)
 	if err != nil {
 		return err
 	}
-	w, err := parsers.ParseWitness(witnessJSON)
-	if err != nil {
-		return err
+
+	var r1cs *types.R1CS
+	if r1csPath != "" {
+		fmt.Println("Reading r1cs file:", r1csPath)
+		r1csFile, err := os.Open(r1csPath) //nolint:gosec
+		if err != nil {
+			return err
+		}
+		defer r1csFile.Close() //nolint:errcheck,gosec
+		r1cs, err = parsers.ParseR1CS(r1csFile)
+		if err != nil {
+			return err
+		}
 	}
 
 	fmt.Println("Generating the proof")
 	beforeT := time.Now()
-	proof, pubSignals, err := prover.GenerateProof(pk, w)
+	var proof *types.Proof
+	var pubSignals []*big.Int
+	if r1cs != nil {
+		proof, pubSignals, err = prover.GenerateCheckedProof(pk, r1cs, w)
+	} else {
+		proof, pubSignals, err = prover.GenerateProof(pk, w)
+	}
 	if err != nil {
 		return err
 	}
@@ -109,7 +187,7 @@ func cmdProve(provingKeyPath, witnessPath, proofPath, publicPath string) error {
 	return nil
 }
 
-func cmdVerify(proofPath, verificationKeyPath, publicPath string) error {
+func cmdVerify(proofPath, verificationKeyPath, publicPath string, evm bool) error {
 	fmt.Println("zkSNARK Groth16 verifier")
 
 	proofJSON, err := ioutil.ReadFile(proofPath) //nolint:gosec
@@ -138,32 +216,298 @@ func cmdVerify(proofPath, verificationKeyPath, publicPath string) error {
 		return err
 	}
 
-	v := verifier.Verify(vk, proof, public)
-	fmt.Println("verification:", v)
+	err = verifier.CheckProof(vk, proof, public)
+	fmt.Println("verification:", err == nil)
+	if err != nil {
+		return err
+	}
+
+	if evm {
+		res, err := solidity.VerifyWithPrecompiles(vk, proof, public, solidity.Istanbul)
+		if err != nil {
+			return err
+		}
+		fmt.Println("EVM precompiles verification:", res.Valid)
+		fmt.Println("EVM precompiles gas:", res.Gas)
+	}
 	return nil
 }
 
-func cmdConvert(provingKeyPath, provingKeyBinPath string) error {
+func cmdConvert(provingKeyPath, provingKeyBinPath string, version int) error {
 	fmt.Println("Conversion tool")
 
-	provingKeyJSON, err := ioutil.ReadFile(provingKeyPath) //nolint:gosec
+	fmt.Printf("Converting proving key (%s)\nto go proving key binary v%d (%s)\n",
+		provingKeyPath, version, provingKeyBinPath)
+	if strings.HasSuffix(provingKeyPath, ".go.bin") {
+		return convertPkGoBin(provingKeyPath, provingKeyBinPath, version)
+	}
+
+	pk, err := readPk(provingKeyPath)
 	if err != nil {
 		return err
 	}
-	pk, err := parsers.ParsePk(provingKeyJSON)
+	pkGBin, err := parsers.PkToGoBinVersion(pk, version)
 	if err != nil {
 		return err
 	}
+	if err = ioutil.WriteFile(provingKeyBinPath, pkGBin, 0600); err != nil {
+		return err
+	}
 
-	fmt.Printf("Converting proving key json (%s)\nto go proving key binary (%s)\n",
-		provingKeyPath, provingKeyBinPath)
-	pkGBin, err := parsers.PkToGoBin(pk)
+	return nil
+}
+
+// convertPkGoBin converts the go-snark binary proving key of the path, in the
+// v1 or v2 format, into the given version of the format, written once the
+// conversion succeeded
+func convertPkGoBin(provingKeyPath, provingKeyBinPath string, version int) error {
+	in, err := os.Open(provingKeyPath) //nolint:gosec
 	if err != nil {
 		return err
 	}
-	if err = ioutil.WriteFile(provingKeyBinPath, pkGBin, 0600); err != nil {
+	defer in.Close() //nolint:errcheck,gosec
+	var out bytes.Buffer
+	if err := parsers.ConvertPkGoBin(in, &out, version); err != nil {
 		return err
 	}
+	return ioutil.WriteFile(provingKeyBinPath, out.Bytes(), 0600)
+}
 
+func cmdSolidity(verificationKeyPath, contractPath string) error {
+	fmt.Println("Solidity verifier generator")
+
+	vkJSON, err := ioutil.ReadFile(verificationKeyPath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	vk, err := parsers.ParseVk(vkJSON)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(contractPath)
+	if err != nil {
+		return err
+	}
+	if err := solidity.GenerateVerifier(f, vk); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Println("Verifier contract stored at:", contractPath)
 	return nil
 }
+
+func cmdCalldata(proofPath, publicPath string) error {
+	proofJSON, err := ioutil.ReadFile(proofPath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	publicJSON, err := ioutil.ReadFile(publicPath) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	proof, err := parsers.ParseProof(proofJSON)
+	if err != nil {
+		return err
+	}
+	public, err := parsers.ParsePublicSignals(publicJSON)
+	if err != nil {
+		return err
+	}
+
+	data, err := solidity.Calldata(proof, public)
+	if err != nil {
+		return err
+	}
+	fmt.Println("0x" + hex.EncodeToString(data))
+	return nil
+}
+
+func cmdServe(addr, vksFlag, verificationKeyPath string, maxRequestSize int64,
+	maxConcurrent int) error {
+	fmt.Println("zkSNARK Groth16 verification server")
+
+	paths, err := parseKeyPaths(vksFlag, verificationKeyPath)
+	if err != nil {
+		return err
+	}
+	vks := make(map[string]*types.Vk, len(paths))
+	for name, path := range paths {
+		fmt.Printf("Reading verification key %s: %s\n", name, path)
+		vkJSON, err := ioutil.ReadFile(path) //nolint:gosec
+		if err != nil {
+			return err
+		}
+		if vks[name], err = parsers.ParseVk(vkJSON); err != nil {
+			return fmt.Errorf("verification key %s: %w", name, err)
+		}
+	}
+
+	s, err := server.NewVerifierServer(vks, maxRequestSize, maxConcurrent)
+	if err != nil {
+		return err
+	}
+	return listenAndServe(addr, s)
+}
+
+func cmdDaemon(addr, pksFlag, provingKeyPath string, workers, maxQueue int,
+	maxRequestSize int64) error {
+	fmt.Println("zkSNARK Groth16 prover daemon")
+
+	paths, err := parseKeyPaths(pksFlag, provingKeyPath)
+	if err != nil {
+		return err
+	}
+	pks := make(map[string]*types.Pk, len(paths))
+	for name, path := range paths {
+		fmt.Printf("Reading proving key %s: %s\n", name, path)
+		beforeT := time.Now()
+		if pks[name], err = readPk(path); err != nil {
+			return fmt.Errorf("proving key %s: %w", name, err)
+		}
+		fmt.Println("proving key parsing time elapsed:", time.Since(beforeT))
+	}
+
+	s, err := server.NewProverServer(pks, workers, maxQueue, maxRequestSize)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return listenAndServe(addr, s)
+}
+
+// parseKeyPaths parses the comma separated list of name=path keys, returning
+// defaultPath as "default" when the list is empty
+func parseKeyPaths(list, defaultPath string) (map[string]string, error) {
+	if list == "" {
+		return map[string]string{"default": defaultPath}, nil
+	}
+	paths := make(map[string]string)
+	for _, nv := range strings.Split(list, ",") {
+		kv := strings.SplitN(nv, "=", 2) //nolint:gomnd
+		if len(kv) != 2 {                //nolint:gomnd
+			return nil, fmt.Errorf("invalid key %q, expected name=path", nv)
+		}
+		paths[kv[0]] = kv[1]
+	}
+	return paths, nil
+}
+
+// readPk reads the proving key in the format given by the file extension:
+// go-snark binary (.go.bin), wasmsnark binary (.bin), snarkjs zkey (.zkey) or
+// JSON
+func readPk(path string) (*types.Pk, error) {
+	if strings.HasSuffix(path, ".zkey") {
+		f, err := os.Open(path) //nolint:gosec
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close() //nolint:errcheck,gosec
+		pk, _, err := parsers.ParseZkey(f)
+		return pk, err
+	}
+	if strings.HasSuffix(path, ".bin") {
+		f, err := os.Open(path) //nolint:gosec
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close() //nolint:errcheck,gosec
+		if strings.HasSuffix(path, ".go.bin") {
+			return parsers.ParsePkGoBin(f)
+		}
+		return parsers.ParsePkBin(f)
+	}
+	pkJSON, err := ioutil.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	return parsers.ParsePk(pkJSON)
+}
+
+// readWitness reads the witness in the format given by the file extension:
+// circom binary (.wtns), wasmsnark binary (.bin) or JSON
+func readWitness(path string, pk *types.Pk) (types.Witness, error) {
+	if strings.HasSuffix(path, ".wtns") || strings.HasSuffix(path, ".bin") {
+		f, err := os.Open(path) //nolint:gosec
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close() //nolint:errcheck,gosec
+		if strings.HasSuffix(path, ".wtns") {
+			return parsers.ParseWtns(f, pk)
+		}
+		return parsers.ParseWitnessBin(f)
+	}
+	witnessJSON, err := ioutil.ReadFile(path) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	return parsers.ParseWitness(witnessJSON)
+}
+
+// listenAndServe serves the handler on the TCP address, or on the Unix socket
+// path when the address is prefixed by "unix:", until an interrupt or
+// termination signal. A stale socket, left by a process that did not stop
+// cleanly, is removed before listening, and the socket is removed when the
+// listener is closed on shutdown.
+func listenAndServe(addr string, h http.Handler) error {
+	network := "tcp"
+	if strings.HasPrefix(addr, "unix:") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix:")
+		if err := removeStaleSocket(addr); err != nil {
+			return err
+		}
+	}
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,  //nolint:gomnd
+		ReadTimeout:       60 * time.Second,  //nolint:gomnd
+		WriteTimeout:      60 * time.Second,  //nolint:gomnd
+		IdleTimeout:       120 * time.Second, //nolint:gomnd
+	}
+	fmt.Printf("Listening on: %s %s\n", network, addr)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(l)
+	}()
+	select {
+	case err := <-errc:
+		return err
+	case sig := <-stop:
+		fmt.Println("Shutting down on", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second) //nolint:gomnd
+		defer cancel()
+		return srv.Shutdown(ctx)
+	}
+}
+
+// removeStaleSocket removes the Unix socket file of the path when no process
+// is listening on it
+func removeStaleSocket(path string) error {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a unix socket", path)
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		_ = c.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
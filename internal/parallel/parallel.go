@@ -0,0 +1,35 @@
+package parallel
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Ranges calls f concurrently for consecutive ranges of [0, n), one for each
+// one of the available CPUs but no more than n, where part, lower than
+// runtime.NumCPU(), is the index of the range
+func Ranges(n int, f func(part, start, end int)) {
+	numcpu := runtime.NumCPU()
+	if numcpu > n {
+		numcpu = n
+	}
+	var wg sync.WaitGroup
+	wg.Add(numcpu)
+	for cpu := 0; cpu < numcpu; cpu++ {
+		go func(cpu int) {
+			f(cpu, cpu*n/numcpu, (cpu+1)*n/numcpu)
+			wg.Done()
+		}(cpu)
+	}
+	wg.Wait()
+}
+
+// For calls f for each index in [0, n) distributing the work between the
+// available CPUs
+func For(n int, f func(i int)) {
+	Ranges(n, func(_, start, end int) {
+		for i := start; i < end; i++ {
+			f(i)
+		}
+	})
+}
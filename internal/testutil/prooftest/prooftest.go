@@ -0,0 +1,27 @@
+package prooftest
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/prover"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// CubicProofs generates a trusted setup of the testutil.CubicR1CS and n
+// proofs of it, for x = 2, 3, ... It is not part of testutil, as the tests of
+// the prover and the setup use testutil.
+func CubicProofs(tb testing.TB, n int) (*types.Vk, []*types.Proof, [][]*big.Int) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(tb, err)
+	proofs := make([]*types.Proof, n)
+	inputs := make([][]*big.Int, n)
+	for i := 0; i < n; i++ {
+		proofs[i], inputs[i], err = prover.GenerateProof(pk, testutil.CubicWitness(int64(i+2)))
+		require.Nil(tb, err)
+	}
+	return vk, proofs, inputs
+}
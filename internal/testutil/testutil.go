@@ -0,0 +1,46 @@
+package testutil
+
+import (
+	"math/big"
+
+	"github.com/vocdoni/go-snark/types"
+)
+
+// CubicR1CS returns the R1CS of the circuit x^3 + x + 5 = out, with the
+// wires [one, out, x, x^2, x^3, x^3+x]
+func CubicR1CS() *types.R1CS {
+	one := big.NewInt(1)
+	return &types.R1CS{
+		NVars:   6,
+		NPublic: 1,
+		Constraints: []types.Constraint{
+			{
+				A: types.LinearCombination{2: one},
+				B: types.LinearCombination{2: one},
+				C: types.LinearCombination{3: one},
+			},
+			{
+				A: types.LinearCombination{3: one},
+				B: types.LinearCombination{2: one},
+				C: types.LinearCombination{4: one},
+			},
+			{
+				A: types.LinearCombination{4: one, 2: one},
+				B: types.LinearCombination{0: one},
+				C: types.LinearCombination{5: one},
+			},
+			{
+				A: types.LinearCombination{5: one, 0: big.NewInt(5)},
+				B: types.LinearCombination{0: one},
+				C: types.LinearCombination{1: one},
+			},
+		},
+	}
+}
+
+// CubicWitness returns the witness of the CubicR1CS for the input x
+func CubicWitness(x int64) types.Witness {
+	x3 := x * x * x
+	return types.Witness{big.NewInt(1), big.NewInt(x3 + x + 5), big.NewInt(x),
+		big.NewInt(x * x), big.NewInt(x3), big.NewInt(x3 + x)}
+}
@@ -0,0 +1,216 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// abiHeadWords is the number of 32 bytes words of the head of the ABI
+// encoding of (uint256[2] a, uint256[2][2] b, uint256[2] c, uint256[] input):
+// 2 for a, 4 for b, 2 for c and the offset of input
+const abiHeadWords = 9
+
+// ProofToABI returns the Ethereum ABI encoding of the arguments
+// (uint256[2] a, uint256[2][2] b, uint256[2] c, uint256[] input) of the proof
+// and its public signals, as used by the verifier contracts, where b has the
+// G2 coordinates in the format of ProofToSmartContractFormat
+func ProofToABI(p *types.Proof, pubSignals []*big.Int) ([]byte, error) {
+	if p == nil || p.A == nil || p.B == nil || p.C == nil {
+		return nil, fmt.Errorf("invalid proof: missing A, B or C")
+	}
+	b := make([]byte, 0, 32*(abiHeadWords+1+len(pubSignals)))
+	// the bn256 encoding of B has the imaginary part of each coordinate
+	// first, as the ABI b
+	b = append(b, p.A.Marshal()...)
+	b = append(b, p.B.Marshal()...)
+	b = append(b, p.C.Marshal()...)
+	b = append(b, abiWord(big.NewInt(32*abiHeadWords))...) //nolint:gomnd
+	b = append(b, abiWord(big.NewInt(int64(len(pubSignals))))...)
+	for i, s := range pubSignals {
+		if s == nil || s.Sign() < 0 || s.Cmp(types.R) >= 0 {
+			return nil, fmt.Errorf("public signal %d out of the field", i)
+		}
+		b = append(b, abiWord(s)...)
+	}
+	return b, nil
+}
+
+// ProofToABIHex returns the ProofToABI encoding as a single 0x prefixed
+// hexadecimal string, for the text of the arguments as snarkjs generatecall
+// prints it see ProofToSolidityCalldata
+func ProofToABIHex(p *types.Proof, pubSignals []*big.Int) (string, error) {
+	b, err := ProofToABI(p, pubSignals)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(b), nil
+}
+
+// ParseProofABI parses the Ethereum ABI encoding of the arguments
+// (uint256[2] a, uint256[2][2] b, uint256[2] c, uint256[] input) into the
+// proof and its public signals
+func ParseProofABI(b []byte) (*types.Proof, []*big.Int, error) {
+	return ReadProofABI(bytes.NewReader(b))
+}
+
+// ReadProofABI reads the Ethereum ABI encoding of the arguments of the proof
+// and its public signals, as ParseProofABI, until the end of r
+func ReadProofABI(r io.Reader) (*types.Proof, []*big.Int, error) {
+	b, err := readNBytes(r, 32*(abiHeadWords+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ABI encoding: %w", err)
+	}
+	offset := new(big.Int).SetBytes(b[32*(abiHeadWords-1) : 32*abiHeadWords])
+	if offset.Cmp(big.NewInt(32*abiHeadWords)) != 0 { //nolint:gomnd
+		return nil, nil, fmt.Errorf("invalid ABI input offset: %s", offset)
+	}
+	n := new(big.Int).SetBytes(b[32*abiHeadWords : 32*(abiHeadWords+1)])
+	if !n.IsUint64() || n.Uint64() > math.MaxInt32 {
+		return nil, nil, fmt.Errorf("invalid ABI input length: %s", n)
+	}
+
+	var p types.Proof
+	p.A = new(bn256.G1)
+	if _, err := p.A.Unmarshal(b[:64]); err != nil {
+		return nil, nil, fmt.Errorf("invalid A: %w", err)
+	}
+	p.B = new(bn256.G2)
+	if _, err := p.B.Unmarshal(b[64:192]); err != nil {
+		return nil, nil, fmt.Errorf("invalid B: %w", err)
+	}
+	p.C = new(bn256.G1)
+	if _, err := p.C.Unmarshal(b[192:256]); err != nil {
+		return nil, nil, fmt.Errorf("invalid C: %w", err)
+	}
+
+	// the public signals are appended as they are read, so a wrong length
+	// does not allocate them in advance
+	pubSignals := []*big.Int{}
+	for i := 0; i < int(n.Uint64()); i++ {
+		w, err := readNBytes(r, 32) //nolint:gomnd
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid ABI input length: %s, %w", n, err)
+		}
+		pubSignals = append(pubSignals, new(big.Int).SetBytes(w))
+		if pubSignals[i].Cmp(types.R) >= 0 {
+			return nil, nil, fmt.Errorf("public signal %d out of the field", i)
+		}
+	}
+	if _, err := io.ReadFull(r, make([]byte, 1)); err != io.EOF {
+		return nil, nil, fmt.Errorf("invalid ABI input length: %s, unexpected data after"+
+			" the input", n)
+	}
+	return &p, pubSignals, nil
+}
+
+// ParseProofABIHex parses the ProofToABIHex encoding, with or without the 0x
+// prefix, into the proof and its public signals
+func ParseProofABIHex(s string) (*types.Proof, []*big.Int, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "0x")
+	if len(s)%2 != 0 {
+		return nil, nil, hex.ErrLength
+	}
+	return ReadProofABI(hex.NewDecoder(strings.NewReader(s)))
+}
+
+// ReadProofABIHex reads the ProofToABIHex encoding, with or without the 0x
+// prefix, as ParseProofABIHex
+func ReadProofABIHex(r io.Reader) (*types.Proof, []*big.Int, error) {
+	s, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ParseProofABIHex(string(s))
+}
+
+// ProofToSolidityCalldata returns the text of the arguments
+// (uint256[2] a, uint256[2][2] b, uint256[2] c, uint256[] input) of the proof
+// and its public signals as snarkjs generatecall prints it, the 0x prefixed
+// hexadecimal words of ProofToABI quoted in arrays:
+// ["0x..", "0x.."],[["0x..", "0x.."],["0x..", "0x.."]],["0x..", "0x.."],["0x..",...]
+func ProofToSolidityCalldata(p *types.Proof, pubSignals []*big.Int) (string, error) {
+	b, err := ProofToABI(p, pubSignals)
+	if err != nil {
+		return "", err
+	}
+	w := make([]string, len(b)/32) //nolint:gomnd
+	for i := range w {
+		w[i] = `"0x` + hex.EncodeToString(b[32*i:32*(i+1)]) + `"`
+	}
+	// the offset and length words of input are not part of the text
+	return "[" + w[0] + ", " + w[1] + "]," +
+		"[[" + w[2] + ", " + w[3] + "],[" + w[4] + ", " + w[5] + "]]," +
+		"[" + w[6] + ", " + w[7] + "]," +
+		"[" + strings.Join(w[abiHeadWords+1:], ",") + "]", nil
+}
+
+// ParseProofSolidityCalldata parses the text of the arguments of the proof
+// and its public signals as snarkjs generatecall prints it, see
+// ProofToSolidityCalldata
+func ParseProofSolidityCalldata(s string) (*types.Proof, []*big.Int, error) {
+	var args []json.RawMessage
+	if err := json.Unmarshal([]byte("["+s+"]"), &args); err != nil {
+		return nil, nil, fmt.Errorf("invalid calldata: %w", err)
+	}
+	if len(args) != 4 { //nolint:gomnd
+		return nil, nil, fmt.Errorf("invalid calldata: got %d arguments, expected 4", len(args))
+	}
+	var a, c, input []string
+	var b [][]string
+	if err := json.Unmarshal(args[0], &a); err != nil || len(a) != 2 {
+		return nil, nil, fmt.Errorf("invalid calldata a: %s", args[0])
+	}
+	if err := json.Unmarshal(args[1], &b); err != nil || len(b) != 2 ||
+		len(b[0]) != 2 || len(b[1]) != 2 {
+		return nil, nil, fmt.Errorf("invalid calldata b: %s", args[1])
+	}
+	if err := json.Unmarshal(args[2], &c); err != nil || len(c) != 2 {
+		return nil, nil, fmt.Errorf("invalid calldata c: %s", args[2])
+	}
+	if err := json.Unmarshal(args[3], &input); err != nil {
+		return nil, nil, fmt.Errorf("invalid calldata input: %s", args[3])
+	}
+
+	words := append(append(append(a, b[0]...), b[1]...), c...)
+	buf := make([]byte, 0, 32*(abiHeadWords+1+len(input)))
+	for _, w := range words {
+		v, err := parseCalldataWord(w)
+		if err != nil {
+			return nil, nil, err
+		}
+		buf = append(buf, abiWord(v)...)
+	}
+	buf = append(buf, abiWord(big.NewInt(32*abiHeadWords))...) //nolint:gomnd
+	buf = append(buf, abiWord(big.NewInt(int64(len(input))))...)
+	for _, w := range input {
+		v, err := parseCalldataWord(w)
+		if err != nil {
+			return nil, nil, err
+		}
+		buf = append(buf, abiWord(v)...)
+	}
+	return ParseProofABI(buf)
+}
+
+// parseCalldataWord parses a 0x prefixed hexadecimal uint256
+func parseCalldataWord(s string) (*big.Int, error) {
+	v, ok := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16) //nolint:gomnd
+	if !ok || !strings.HasPrefix(s, "0x") || v.Sign() < 0 || v.BitLen() > 256 {
+		return nil, fmt.Errorf("invalid calldata word: %q", s)
+	}
+	return v, nil
+}
+
+// abiWord returns the 32 bytes big-endian encoding of the non negative v
+func abiWord(v *big.Int) []byte {
+	return v.FillBytes(make([]byte, 32)) //nolint:gomnd
+}
@@ -0,0 +1,165 @@
+package parsers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/types"
+)
+
+func randomProof(t *testing.T) *types.Proof {
+	_, a, err := bn256.RandomG1(rand.Reader)
+	require.Nil(t, err)
+	_, b, err := bn256.RandomG2(rand.Reader)
+	require.Nil(t, err)
+	_, c, err := bn256.RandomG1(rand.Reader)
+	require.Nil(t, err)
+	return &types.Proof{A: a, B: b, C: c}
+}
+
+func TestProofABI(t *testing.T) {
+	proof := randomProof(t)
+	pubSignals := []*big.Int{big.NewInt(0), big.NewInt(35),
+		new(big.Int).Sub(types.R, big.NewInt(1))}
+
+	b, err := ProofToABI(proof, pubSignals)
+	require.Nil(t, err)
+	require.Equal(t, 32*13, len(b))
+	words := make([]string, len(b)/32)
+	for i := range words {
+		words[i] = new(big.Int).SetBytes(b[32*i : 32*(i+1)]).String()
+	}
+	ps := ProofToSmartContractFormat(proof)
+	assert.Equal(t, []string{ps.A[0], ps.A[1], ps.B[0][0], ps.B[0][1], ps.B[1][0], ps.B[1][1],
+		ps.C[0], ps.C[1], "288", "3", "0", "35", pubSignals[2].String()}, words)
+
+	// round trip with the JSON formats
+	proofJSON, err := ProofToJSON(proof)
+	require.Nil(t, err)
+	parsedProof, err := ParseProof(proofJSON)
+	require.Nil(t, err)
+	h, err := ProofToABIHex(parsedProof, pubSignals)
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(h, "0x"))
+
+	for _, s := range []string{h, h[2:], " " + h + "\n"} {
+		p, public, err := ParseProofABIHex(s)
+		require.Nil(t, err)
+		pJSON, err := ProofToJSON(p)
+		require.Nil(t, err)
+		assert.Equal(t, proofJSON, pJSON)
+		assert.Equal(t, ArrayBigIntToString(pubSignals), ArrayBigIntToString(public))
+	}
+
+	// streams
+	p, public, err := ReadProofABI(iotest.OneByteReader(bytes.NewReader(b)))
+	require.Nil(t, err)
+	assert.Equal(t, proof.B.Marshal(), p.B.Marshal())
+	assert.Equal(t, ArrayBigIntToString(pubSignals), ArrayBigIntToString(public))
+	p, public, err = ReadProofABIHex(strings.NewReader(h + "\n"))
+	require.Nil(t, err)
+	assert.Equal(t, proof.C.Marshal(), p.C.Marshal())
+	assert.Equal(t, ArrayBigIntToString(pubSignals), ArrayBigIntToString(public))
+
+	// no public signals
+	b, err = ProofToABI(proof, nil)
+	require.Nil(t, err)
+	_, public, err = ParseProofABI(b)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(public))
+}
+
+func TestProofSolidityCalldata(t *testing.T) {
+	proof := randomProof(t)
+	pubSignals := []*big.Int{big.NewInt(35), new(big.Int).Sub(types.R, big.NewInt(1))}
+
+	s, err := ProofToSolidityCalldata(proof, pubSignals)
+	require.Nil(t, err)
+	ps := ProofToSmartContractFormat(proof)
+	w := func(d string) string {
+		v, ok := new(big.Int).SetString(d, 10)
+		require.True(t, ok)
+		return fmt.Sprintf(`"0x%064x"`, v)
+	}
+	assert.Equal(t, "["+w(ps.A[0])+", "+w(ps.A[1])+"],"+
+		"[["+w(ps.B[0][0])+", "+w(ps.B[0][1])+"],["+w(ps.B[1][0])+", "+w(ps.B[1][1])+"]],"+
+		"["+w(ps.C[0])+", "+w(ps.C[1])+"],"+
+		"["+w("35")+","+w(pubSignals[1].String())+"]", s)
+
+	p, public, err := ParseProofSolidityCalldata(s)
+	require.Nil(t, err)
+	assert.Equal(t, proof.A.Marshal(), p.A.Marshal())
+	assert.Equal(t, proof.B.Marshal(), p.B.Marshal())
+	assert.Equal(t, proof.C.Marshal(), p.C.Marshal())
+	assert.Equal(t, ArrayBigIntToString(pubSignals), ArrayBigIntToString(public))
+
+	s, err = ProofToSolidityCalldata(proof, nil)
+	require.Nil(t, err)
+	assert.True(t, strings.HasSuffix(s, ",[]"))
+	_, public, err = ParseProofSolidityCalldata(s)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(public))
+
+	a := "[" + w(ps.A[0]) + ", " + w(ps.A[1]) + "]"
+	rest := s[len(a):]
+	for _, bad := range []string{
+		"",
+		a,
+		a + rest + ",[]",
+		"[" + w(ps.A[0]) + "]" + rest,
+		`["0x1", "0x3"]` + rest,
+		"[" + strings.Replace(a[1:], "0x", "", 1) + rest,
+		`["-0x1", "0x2"]` + rest,
+		`["0x1` + strings.Repeat("0", 64) + `", "0x2"]` + rest,
+		a + rest[:len(rest)-1] + `"0x` + types.R.Text(16) + `"]`,
+	} {
+		_, _, err = ParseProofSolidityCalldata(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestProofABIInvalid(t *testing.T) {
+	proof := randomProof(t)
+	_, err := ProofToABI(proof, []*big.Int{types.R})
+	assert.NotNil(t, err)
+	_, err = ProofToABI(&types.Proof{A: proof.A}, nil)
+	assert.NotNil(t, err)
+
+	b, err := ProofToABI(proof, []*big.Int{big.NewInt(1)})
+	require.Nil(t, err)
+
+	_, _, err = ParseProofABI(b[:len(b)-32])
+	assert.NotNil(t, err)
+	_, _, err = ParseProofABI(b[:len(b)-1])
+	assert.NotNil(t, err)
+
+	bad := append([]byte{}, b...)
+	bad[32*9-1]++ // offset
+	_, _, err = ParseProofABI(bad)
+	assert.NotNil(t, err)
+
+	bad = append([]byte{}, b...)
+	bad[63]++ // A.Y, not on the curve
+	_, _, err = ParseProofABI(bad)
+	assert.NotNil(t, err)
+
+	bad = append([]byte{}, b...)
+	copy(bad[32*10:], types.R.Bytes())
+	_, _, err = ParseProofABI(bad)
+	assert.NotNil(t, err)
+
+	_, _, err = ParseProofABIHex("0xzz")
+	assert.NotNil(t, err)
+	_, _, err = ParseProofABIHex("0x0")
+	assert.NotNil(t, err)
+	_, _, err = ReadProofABI(bytes.NewReader(append(b, b...)))
+	assert.NotNil(t, err)
+}
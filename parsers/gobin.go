@@ -0,0 +1,412 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// The go-snark binary format v2 of the ProvingKey is made of the magic, the
+// version, the curve, the number of sections, the section table, with the
+// type, the uint64 offset from the start of the file and the uint64 length of
+// each section, the sections in the order of the table and the sha256 hash of
+// all the previous bytes. The integers are little-endian.
+const (
+	goBinMagic      = "gsnk"
+	goBinVersion    = 2
+	goBinCurveBN256 = 1
+
+	goBinSectionHeader = 1
+	goBinSectionPolsA  = 2
+	goBinSectionPolsB  = 3
+	goBinSectionA      = 4
+	goBinSectionB1     = 5
+	goBinSectionB2     = 6
+	goBinSectionC      = 7
+	goBinSectionHExps  = 8
+
+	// goBinHeaderSize is the size of nVars, nPublic, domainSize, VkAlpha1,
+	// VkBeta1, VkDelta1, VkBeta2 and VkDelta2
+	goBinHeaderSize = 12 + 3*64 + 2*128
+	// goBinMaxSections is the maximum number of sections of the table
+	goBinMaxSections = 1024
+)
+
+// goBinSection is a section of the go-snark binary format v2
+type goBinSection struct {
+	sType uint32
+	data  []byte
+}
+
+// PkToGoBinV2 converts the ProvingKey (*types.Pk) into the go-snark binary
+// format v2, which has a header with the version, the curve and the section
+// table, and a trailing hash of its content. ParsePkGoBin reads both the v1
+// and v2 formats.
+//
+//nolint:gomnd
+func PkToGoBinV2(pk *types.Pk) ([]byte, error) {
+	if pk == nil {
+		return nil, fmt.Errorf("nil proving key")
+	}
+	if len(pk.PolsA) != pk.NVars || len(pk.PolsB) != pk.NVars ||
+		len(pk.A) != pk.NVars || len(pk.B1) != pk.NVars || len(pk.B2) != pk.NVars ||
+		len(pk.C) != pk.NVars || pk.NPublic >= pk.NVars {
+		return nil, fmt.Errorf("proving key lengths do not match its %d variables", pk.NVars)
+	}
+	if len(pk.HExps) < pk.DomainSize+1 {
+		return nil, fmt.Errorf("proving key has %d HExps, expected %d",
+			len(pk.HExps), pk.DomainSize+1)
+	}
+	if pk.VkAlpha1 == nil || pk.VkBeta1 == nil || pk.VkDelta1 == nil ||
+		pk.VkBeta2 == nil || pk.VkDelta2 == nil {
+		return nil, fmt.Errorf("proving key is missing VkAlpha1, VkBeta1, VkDelta1, VkBeta2" +
+			" or VkDelta2")
+	}
+	if hasNilG1(pk.A) || hasNilG1(pk.B1) || hasNilG2(pk.B2) ||
+		hasNilG1(pk.C[pk.NPublic+1:]) || hasNilG1(pk.HExps[:pk.DomainSize+1]) {
+		return nil, fmt.Errorf("proving key has nil points")
+	}
+
+	header := make([]byte, 12, goBinHeaderSize)
+	binary.LittleEndian.PutUint32(header[:4], uint32(pk.NVars))
+	binary.LittleEndian.PutUint32(header[4:8], uint32(pk.NPublic))
+	binary.LittleEndian.PutUint32(header[8:12], uint32(pk.DomainSize))
+	header = append(header, pk.VkAlpha1.Marshal()...)
+	header = append(header, pk.VkBeta1.Marshal()...)
+	header = append(header, pk.VkDelta1.Marshal()...)
+	header = append(header, pk.VkBeta2.Marshal()...)
+	header = append(header, pk.VkDelta2.Marshal()...)
+
+	return goBinV2Encode([]goBinSection{
+		{goBinSectionHeader, header},
+		{goBinSectionPolsA, goBinPols(pk.PolsA)},
+		{goBinSectionPolsB, goBinPols(pk.PolsB)},
+		{goBinSectionA, goBinG1s(pk.A)},
+		{goBinSectionB1, goBinG1s(pk.B1)},
+		{goBinSectionB2, goBinG2s(pk.B2)},
+		{goBinSectionC, goBinG1s(pk.C[pk.NPublic+1:])},
+		{goBinSectionHExps, goBinG1s(pk.HExps[:pk.DomainSize+1])},
+	}), nil
+}
+
+// goBinV2Encode encodes the sections in the go-snark binary format v2
+//
+//nolint:gomnd
+func goBinV2Encode(sections []goBinSection) []byte {
+	size := 16 + 20*len(sections)
+	for _, s := range sections {
+		size += len(s.data)
+	}
+	b := make([]byte, 16, size+sha256.Size)
+	copy(b, goBinMagic)
+	binary.LittleEndian.PutUint32(b[4:8], goBinVersion)
+	binary.LittleEndian.PutUint32(b[8:12], goBinCurveBN256)
+	binary.LittleEndian.PutUint32(b[12:16], uint32(len(sections)))
+	o := 16 + 20*len(sections)
+	var e [20]byte
+	for _, s := range sections {
+		binary.LittleEndian.PutUint32(e[:4], s.sType)
+		binary.LittleEndian.PutUint64(e[4:12], uint64(o))
+		binary.LittleEndian.PutUint64(e[12:20], uint64(len(s.data)))
+		b = append(b, e[:]...)
+		o += len(s.data)
+	}
+	for _, s := range sections {
+		b = append(b, s.data...)
+	}
+	h := sha256.Sum256(b)
+	return append(b, h[:]...)
+}
+
+//nolint:gomnd
+func goBinPols(pols []map[int]*big.Int) []byte {
+	var b []byte
+	var n [4]byte
+	for i := range pols {
+		binary.LittleEndian.PutUint32(n[:], uint32(len(pols[i])))
+		b = append(b, n[:]...)
+		for _, j := range sortedKeys(pols[i]) {
+			binary.LittleEndian.PutUint32(n[:], uint32(j))
+			b = append(b, n[:]...)
+			b = append(b, addPadding32(pols[i][j].Bytes())...)
+		}
+	}
+	return b
+}
+
+func goBinG1s(points []*bn256.G1) []byte {
+	b := make([]byte, 0, len(points)*64) //nolint:gomnd
+	for _, p := range points {
+		b = append(b, p.Marshal()...)
+	}
+	return b
+}
+
+func goBinG2s(points []*bn256.G2) []byte {
+	b := make([]byte, 0, len(points)*128) //nolint:gomnd
+	for _, p := range points {
+		b = append(b, p.Marshal()...)
+	}
+	return b
+}
+
+func hasNilG1(points []*bn256.G1) bool {
+	for _, p := range points {
+		if p == nil {
+			return true
+		}
+	}
+	return false
+}
+
+func hasNilG2(points []*bn256.G2) bool {
+	for _, p := range points {
+		if p == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isGoBinV2 returns true when the data starts with the magic of the go-snark
+// binary format v2
+func isGoBinV2(r *bufio.Reader) bool {
+	magic, err := r.Peek(len(goBinMagic))
+	return err == nil && string(magic) == goBinMagic
+}
+
+// readPkGoBinV2 reads the go-snark binary format v2 of the ProvingKey,
+// checking its hash. The sections must be in the order of their offsets, and
+// the unknown ones are skipped.
+//
+//nolint:gocyclo,gomnd
+func readPkGoBinV2(r *bufio.Reader) (*types.Pk, error) {
+	h := sha256.New()
+	tr := io.TeeReader(r, h)
+
+	b, err := readNBytes(tr, 16)
+	if err != nil {
+		return nil, err
+	}
+	if string(b[:4]) != goBinMagic {
+		return nil, fmt.Errorf("invalid go bin magic")
+	}
+	if v := binary.LittleEndian.Uint32(b[4:8]); v != goBinVersion {
+		return nil, fmt.Errorf("unsupported go bin version: %d", v)
+	}
+	if c := binary.LittleEndian.Uint32(b[8:12]); c != goBinCurveBN256 {
+		return nil, fmt.Errorf("unsupported go bin curve: %d", c)
+	}
+	nSections := int(binary.LittleEndian.Uint32(b[12:16]))
+	if nSections > goBinMaxSections {
+		return nil, fmt.Errorf("too many go bin sections: %d", nSections)
+	}
+	table, err := readNBytes(tr, 20*nSections)
+	if err != nil {
+		return nil, err
+	}
+
+	var pk types.Pk
+	o := uint64(16 + 20*nSections)
+	found := make(map[uint32]bool)
+	for i := 0; i < nSections; i++ {
+		e := table[i*20 : (i+1)*20]
+		sType := binary.LittleEndian.Uint32(e[:4])
+		offset := binary.LittleEndian.Uint64(e[4:12])
+		length := binary.LittleEndian.Uint64(e[12:20])
+		if offset != o {
+			return nil, fmt.Errorf("unexpected go bin section %d offset,"+
+				" expected: %v, actual: %v", sType, o, offset)
+		}
+		o += length
+		if found[sType] {
+			return nil, fmt.Errorf("duplicated go bin section %d", sType)
+		}
+		found[sType] = true
+		if sType != goBinSectionHeader && !found[goBinSectionHeader] {
+			return nil, fmt.Errorf("go bin section %d before the header", sType)
+		}
+
+		sr := &io.LimitedReader{R: tr, N: int64(length)}
+		switch sType {
+		case goBinSectionHeader:
+			err = readGoBinHeader(sr, &pk)
+		case goBinSectionPolsA:
+			pk.PolsA, err = readGoBinPols(sr, pk.NVars)
+		case goBinSectionPolsB:
+			pk.PolsB, err = readGoBinPols(sr, pk.NVars)
+		case goBinSectionA:
+			pk.A, err = readGoBinG1s(sr, pk.NVars)
+		case goBinSectionB1:
+			pk.B1, err = readGoBinG1s(sr, pk.NVars)
+		case goBinSectionB2:
+			pk.B2, err = readGoBinG2s(sr, pk.NVars)
+		case goBinSectionC:
+			pk.C, err = readGoBinG1s(sr, pk.NVars-pk.NPublic-1)
+			if err == nil {
+				z := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+				public := make([]*bn256.G1, pk.NPublic+1)
+				for j := range public {
+					public[j] = z
+				}
+				pk.C = append(public, pk.C...)
+			}
+		case goBinSectionHExps:
+			pk.HExps, err = readGoBinG1s(sr, pk.DomainSize+1)
+		default:
+			_, err = io.Copy(ioutil.Discard, sr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("go bin section %d: %w", sType, err)
+		}
+		if sr.N != 0 {
+			return nil, fmt.Errorf("unexpected go bin section %d length: %v",
+				sType, length)
+		}
+	}
+	for _, sType := range []uint32{goBinSectionHeader, goBinSectionPolsA,
+		goBinSectionPolsB, goBinSectionA, goBinSectionB1, goBinSectionB2,
+		goBinSectionC, goBinSectionHExps} {
+		if !found[sType] {
+			return nil, fmt.Errorf("go bin section %d not found", sType)
+		}
+	}
+
+	hash, err := readNBytes(r, sha256.Size)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hash, h.Sum(nil)) {
+		return nil, fmt.Errorf("go bin hash mismatch")
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		return nil, fmt.Errorf("invalid data after the go bin hash")
+	}
+	return &pk, nil
+}
+
+//nolint:gomnd
+func readGoBinHeader(r io.Reader, pk *types.Pk) error {
+	b, err := readNBytes(r, goBinHeaderSize)
+	if err != nil {
+		return err
+	}
+	pk.NVars = int(binary.LittleEndian.Uint32(b[:4]))
+	pk.NPublic = int(binary.LittleEndian.Uint32(b[4:8]))
+	pk.DomainSize = int(binary.LittleEndian.Uint32(b[8:12]))
+	if pk.NPublic >= pk.NVars {
+		return fmt.Errorf("%d public inputs of %d variables", pk.NPublic, pk.NVars)
+	}
+	b = b[12:]
+	pk.VkAlpha1 = new(bn256.G1)
+	if _, err := pk.VkAlpha1.Unmarshal(b[:64]); err != nil {
+		return err
+	}
+	pk.VkBeta1 = new(bn256.G1)
+	if _, err := pk.VkBeta1.Unmarshal(b[64:128]); err != nil {
+		return err
+	}
+	pk.VkDelta1 = new(bn256.G1)
+	if _, err := pk.VkDelta1.Unmarshal(b[128:192]); err != nil {
+		return err
+	}
+	pk.VkBeta2 = new(bn256.G2)
+	if _, err := pk.VkBeta2.Unmarshal(b[192:320]); err != nil {
+		return err
+	}
+	pk.VkDelta2 = new(bn256.G2)
+	if _, err := pk.VkDelta2.Unmarshal(b[320:448]); err != nil {
+		return err
+	}
+	return nil
+}
+
+//nolint:gomnd
+func readGoBinPols(r io.Reader, n int) ([]map[int]*big.Int, error) {
+	var pols []map[int]*big.Int
+	for i := 0; i < n; i++ {
+		b, err := readNBytes(r, 4)
+		if err != nil {
+			return nil, err
+		}
+		keysLength := int(binary.LittleEndian.Uint32(b))
+		polsMap := make(map[int]*big.Int)
+		for j := 0; j < keysLength; j++ {
+			b, err := readNBytes(r, 4+32)
+			if err != nil {
+				return nil, err
+			}
+			polsMap[int(binary.LittleEndian.Uint32(b[:4]))] = new(big.Int).SetBytes(b[4:])
+		}
+		pols = append(pols, polsMap)
+	}
+	return pols, nil
+}
+
+func readGoBinG1s(r io.Reader, n int) ([]*bn256.G1, error) {
+	var points []*bn256.G1
+	for i := 0; i < n; i++ {
+		b, err := readNBytes(r, 64) //nolint:gomnd
+		if err != nil {
+			return nil, err
+		}
+		p := new(bn256.G1)
+		if _, err := p.Unmarshal(b); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+func readGoBinG2s(r io.Reader, n int) ([]*bn256.G2, error) {
+	var points []*bn256.G2
+	for i := 0; i < n; i++ {
+		b, err := readNBytes(r, 128) //nolint:gomnd
+		if err != nil {
+			return nil, err
+		}
+		p := new(bn256.G2)
+		if _, err := p.Unmarshal(b); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// PkToGoBinVersion converts the ProvingKey (*types.Pk) into the given version
+// of the go-snark binary format, with PkToGoBin (1) or PkToGoBinV2 (2)
+func PkToGoBinVersion(pk *types.Pk, version int) ([]byte, error) {
+	switch version {
+	case 1:
+		return PkToGoBin(pk)
+	case goBinVersion:
+		return PkToGoBinV2(pk)
+	default:
+		return nil, fmt.Errorf("unsupported go bin version: %d", version)
+	}
+}
+
+// ConvertPkGoBin converts the go-snark binary ProvingKey of r, in the v1 or v2
+// format, into the given version of the format, writing it to w
+func ConvertPkGoBin(r io.Reader, w io.Writer, version int) error {
+	pk, err := ReadPkGoBin(r)
+	if err != nil {
+		return err
+	}
+	b, err := PkToGoBinVersion(pk, version)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
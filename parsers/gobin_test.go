@@ -0,0 +1,143 @@
+package parsers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/prover"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/types"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+// rehash replaces the trailing hash of the go bin v2 data
+func rehash(b []byte) []byte {
+	b = append([]byte{}, b[:len(b)-sha256.Size]...)
+	h := sha256.Sum256(b)
+	return append(b, h[:]...)
+}
+
+func TestPkGoBinV2(t *testing.T) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	v1, err := PkToGoBin(pk)
+	require.Nil(t, err)
+	v2, err := PkToGoBinV2(pk)
+	require.Nil(t, err)
+
+	// header and section table
+	assert.Equal(t, "gsnk", string(v2[:4]))
+	assert.Equal(t, uint32(2), binary.LittleEndian.Uint32(v2[4:8]))
+	assert.Equal(t, uint32(1), binary.LittleEndian.Uint32(v2[8:12]))
+	require.Equal(t, uint32(8), binary.LittleEndian.Uint32(v2[12:16]))
+	o := uint64(16 + 8*20)
+	for i := 0; i < 8; i++ {
+		e := v2[16+i*20 : 16+(i+1)*20]
+		assert.Equal(t, uint32(i+1), binary.LittleEndian.Uint32(e[:4]))
+		assert.Equal(t, o, binary.LittleEndian.Uint64(e[4:12]))
+		o += binary.LittleEndian.Uint64(e[12:20])
+	}
+	require.Equal(t, o+sha256.Size, uint64(len(v2)))
+	h := sha256.Sum256(v2[:o])
+	assert.Equal(t, h[:], v2[o:])
+
+	pk2, err := ReadPkGoBin(iotest.OneByteReader(bytes.NewReader(v2)))
+	require.Nil(t, err)
+	pkGoBin, err := PkToGoBin(pk2)
+	require.Nil(t, err)
+	assert.Equal(t, v1, pkGoBin)
+	proof, pubSignals, err := prover.GenerateProof(pk2, testutil.CubicWitness(3))
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// conversion between the versions
+	var w bytes.Buffer
+	require.Nil(t, ConvertPkGoBin(bytes.NewReader(v1), &w, 2))
+	assert.Equal(t, v2, w.Bytes())
+	w.Reset()
+	require.Nil(t, ConvertPkGoBin(bytes.NewReader(v2), &w, 1))
+	assert.Equal(t, v1, w.Bytes())
+	assert.NotNil(t, ConvertPkGoBin(bytes.NewReader(v2), &w, 3))
+	b, err := PkToGoBinVersion(pk, 2)
+	require.Nil(t, err)
+	assert.Equal(t, v2, b)
+	_, err = PkToGoBinVersion(pk, 0)
+	assert.NotNil(t, err)
+
+	// keys with nil points are rejected instead of panicking
+	_, err = PkToGoBinV2(nil)
+	assert.NotNil(t, err)
+	for _, f := range []func(pk *types.Pk){
+		func(pk *types.Pk) { pk.VkAlpha1 = nil },
+		func(pk *types.Pk) { pk.VkBeta1 = nil },
+		func(pk *types.Pk) { pk.VkDelta1 = nil },
+		func(pk *types.Pk) { pk.VkBeta2 = nil },
+		func(pk *types.Pk) { pk.VkDelta2 = nil },
+		func(pk *types.Pk) { pk.A[1] = nil },
+		func(pk *types.Pk) { pk.B2[1] = nil },
+		func(pk *types.Pk) { pk.HExps[0] = nil },
+	} {
+		nilPk := *pk
+		nilPk.A = append(nilPk.A[:0:0], pk.A...)
+		nilPk.B2 = append(nilPk.B2[:0:0], pk.B2...)
+		nilPk.HExps = append(nilPk.HExps[:0:0], pk.HExps...)
+		f(&nilPk)
+		_, err = PkToGoBinV2(&nilPk)
+		assert.NotNil(t, err)
+	}
+
+	// unknown sections are skipped
+	sections := []goBinSection{{goBinSectionHeader, nil}}
+	for i := 0; i < 8; i++ {
+		e := v2[16+i*20 : 16+(i+1)*20]
+		offset := binary.LittleEndian.Uint64(e[4:12])
+		length := binary.LittleEndian.Uint64(e[12:20])
+		sections = append(sections, goBinSection{binary.LittleEndian.Uint32(e[:4]),
+			v2[offset : offset+length]})
+	}
+	sections[0] = sections[1]
+	sections[1] = goBinSection{9, []byte{1, 2, 3}}
+	pk2, err = ReadPkGoBin(bytes.NewReader(goBinV2Encode(sections)))
+	require.Nil(t, err)
+	pkGoBin, err = PkToGoBin(pk2)
+	require.Nil(t, err)
+	assert.Equal(t, v1, pkGoBin)
+
+	// section missing, or before the header
+	_, err = ReadPkGoBin(bytes.NewReader(goBinV2Encode(sections[:len(sections)-1])))
+	assert.NotNil(t, err)
+	_, err = ReadPkGoBin(bytes.NewReader(goBinV2Encode(sections[1:])))
+	assert.NotNil(t, err)
+
+	read := func(b []byte) error {
+		_, err := ReadPkGoBin(bytes.NewReader(b))
+		return err
+	}
+	bad := append([]byte{}, v2...)
+	bad[len(bad)-100]++
+	assert.NotNil(t, read(bad))
+	assert.NotNil(t, read(v2[:len(v2)-1]))
+	assert.NotNil(t, read(append(append([]byte{}, v2...), 0)))
+
+	// unsupported version and curve
+	bad = append([]byte{}, v2...)
+	bad[4] = 3
+	assert.NotNil(t, read(rehash(bad)))
+	bad = append([]byte{}, v2...)
+	bad[8] = 2
+	assert.NotNil(t, read(rehash(bad)))
+
+	// offset and length not matching the sections
+	bad = append([]byte{}, v2...)
+	bad[16+20+4]++
+	assert.NotNil(t, read(rehash(bad)))
+	bad = append([]byte{}, v2...)
+	bad[16+12]++
+	assert.NotNil(t, read(rehash(bad)))
+}
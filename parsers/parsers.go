@@ -8,10 +8,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"math/big"
 	"os"
 	"sort"
-	"strconv"
 	"strings"
 
 	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
@@ -59,9 +59,13 @@ type VkString struct {
 
 // ParseWitness parses the json []byte data into the Witness struct
 func ParseWitness(wJSON []byte) (types.Witness, error) {
+	return ReadWitness(bytes.NewReader(wJSON))
+}
+
+// ReadWitness reads the json data into the Witness struct
+func ReadWitness(r io.Reader) (types.Witness, error) {
 	var ws WitnessString
-	err := json.Unmarshal(wJSON, &ws)
-	if err != nil {
+	if err := decodeJSON(r, &ws); err != nil {
 		return nil, err
 	}
 
@@ -78,79 +82,28 @@ func ParseWitness(wJSON []byte) (types.Witness, error) {
 
 // ParsePk parses the json []byte data into the Pk struct
 func ParsePk(pkJSON []byte) (*types.Pk, error) {
-	var pkStr PkString
-	err := json.Unmarshal(pkJSON, &pkStr)
-	if err != nil {
-		return nil, err
-	}
-	pk, err := pkStringToPk(pkStr)
-	return pk, err
+	return ReadPk(bytes.NewReader(pkJSON))
 }
 
-func pkStringToPk(ps PkString) (*types.Pk, error) {
-	var p types.Pk
-	var err error
-
-	p.A, err = arrayStringToG1(ps.A)
-	if err != nil {
-		return nil, err
-	}
-	p.B2, err = arrayStringToG2(ps.B2)
-	if err != nil {
-		return nil, err
-	}
-	p.B1, err = arrayStringToG1(ps.B1)
-	if err != nil {
-		return nil, err
-	}
-	p.C, err = arrayStringToG1(ps.C)
-	if err != nil {
-		return nil, err
-	}
-
-	p.NVars = ps.NVars
-	p.NPublic = ps.NPublic
-
-	p.VkAlpha1, err = stringToG1(ps.VkAlpha1)
-	if err != nil {
-		return nil, err
-	}
-
-	p.VkDelta1, err = stringToG1(ps.VkDelta1)
-	if err != nil {
-		return nil, err
-	}
-
-	p.VkBeta1, err = stringToG1(ps.VkBeta1)
-	if err != nil {
-		return nil, err
-	}
-	p.VkBeta2, err = stringToG2(ps.VkBeta2)
-	if err != nil {
-		return nil, err
-	}
-	p.VkDelta2, err = stringToG2(ps.VkDelta2)
-	if err != nil {
-		return nil, err
-	}
-
-	p.HExps, err = arrayStringToG1(ps.HExps)
-	if err != nil {
-		return nil, err
-	}
-
-	p.DomainSize = ps.DomainSize
+// ReadPk reads the json data into the Pk struct. The data is decoded as it is
+// read, without holding its PkString representation.
+func ReadPk(r io.Reader) (*types.Pk, error) {
+	return decodePk(r)
+}
 
-	p.PolsA, err = polsStringToBigInt(ps.PolsA)
-	if err != nil {
-		return nil, err
+// decodeJSON decodes the json value of r into v, failing if there is more
+// data after it, as json.Unmarshal does
+func decodeJSON(r io.Reader, v interface{}) error {
+	d := json.NewDecoder(r)
+	if err := d.Decode(v); err == io.EOF {
+		return io.ErrUnexpectedEOF
+	} else if err != nil {
+		return err
 	}
-	p.PolsB, err = polsStringToBigInt(ps.PolsB)
-	if err != nil {
-		return nil, err
+	if _, err := d.Token(); err != io.EOF {
+		return fmt.Errorf("invalid data after the json value")
 	}
-
-	return &p, nil
+	return nil
 }
 
 func proofStringToProof(pr ProofString) (*types.Proof, error) {
@@ -176,20 +129,27 @@ func proofStringToProof(pr ProofString) (*types.Proof, error) {
 
 // ParseProof takes a json []byte and outputs the *Proof struct
 func ParseProof(pj []byte) (*types.Proof, error) {
+	return ReadProof(bytes.NewReader(pj))
+}
+
+// ReadProof reads the json data into the *Proof struct
+func ReadProof(r io.Reader) (*types.Proof, error) {
 	var pr ProofString
-	err := json.Unmarshal(pj, &pr)
-	if err != nil {
+	if err := decodeJSON(r, &pr); err != nil {
 		return nil, err
 	}
-	p, err := proofStringToProof(pr)
-	return p, err
+	return proofStringToProof(pr)
 }
 
 // ParsePublicSignals takes a json []byte and outputs the []*big.Int struct
 func ParsePublicSignals(pj []byte) ([]*big.Int, error) {
+	return ReadPublicSignals(bytes.NewReader(pj))
+}
+
+// ReadPublicSignals reads the json data into the []*big.Int struct
+func ReadPublicSignals(r io.Reader) ([]*big.Int, error) {
 	var pr []string
-	err := json.Unmarshal(pj, &pr)
-	if err != nil {
+	if err := decodeJSON(r, &pr); err != nil {
 		return nil, err
 	}
 	var public []*big.Int
@@ -205,13 +165,16 @@ func ParsePublicSignals(pj []byte) ([]*big.Int, error) {
 
 // ParseVk takes a json []byte and outputs the *Vk struct
 func ParseVk(vj []byte) (*types.Vk, error) {
+	return ReadVk(bytes.NewReader(vj))
+}
+
+// ReadVk reads the json data into the *Vk struct
+func ReadVk(r io.Reader) (*types.Vk, error) {
 	var vr VkString
-	err := json.Unmarshal(vj, &vr)
-	if err != nil {
+	if err := decodeJSON(r, &vr); err != nil {
 		return nil, err
 	}
-	v, err := vkStringToVk(vr)
-	return v, err
+	return vkStringToVk(vr)
 }
 
 func vkStringToVk(vr VkString) (*types.Vk, error) {
@@ -248,29 +211,6 @@ func vkStringToVk(vr VkString) (*types.Vk, error) {
 	return &v, nil
 }
 
-// polsStringToBigInt is for taking string polynomials and converting it to *big.Int polynomials
-func polsStringToBigInt(s []map[string]string) ([]map[int]*big.Int, error) {
-	var o []map[int]*big.Int
-	for i := 0; i < len(s); i++ {
-		// var oi map[int]*big.Int
-		oi := make(map[int]*big.Int)
-		for j, v := range s[i] {
-			si, err := stringToBigInt(v)
-			if err != nil {
-				return o, err
-			}
-			// oi = append(oi, si)
-			jInt, err := strconv.Atoi(j)
-			if err != nil {
-				return o, err
-			}
-			oi[jInt] = si
-		}
-		o = append(o, oi)
-	}
-	return o, nil
-}
-
 // ArrayBigIntToString converts an []*big.Int into []string, used to output the Public Signals
 func ArrayBigIntToString(bi []*big.Int) []string {
 	var s []string
@@ -330,6 +270,9 @@ func stringToBytes(s string) ([]byte, error) {
 		return nil, fmt.Errorf("error parsing bigint stringToBytes")
 	}
 	b := bi.Bytes()
+	if len(b) > 32 { //nolint:gomnd
+		return nil, fmt.Errorf("value does not fit in 32 bytes: %s", s)
+	}
 	if len(b) != 32 { //nolint:gomnd
 		b = addZPadding(b)
 	}
@@ -403,6 +346,9 @@ func stringToG1(h []string) (*bn256.G1, error) {
 		}
 		b0 := bi0.Bytes()
 		b1 := bi1.Bytes()
+		if len(b0) > 32 || len(b1) > 32 {
+			return nil, fmt.Errorf("value does not fit in 32 bytes for stringToG1")
+		}
 		if len(b0) != 32 {
 			b0 = addZPadding(b0)
 		}
@@ -420,7 +366,7 @@ func stringToG1(h []string) (*bn256.G1, error) {
 }
 
 func stringToG2(h [][]string) (*bn256.G2, error) {
-	if len(h) <= 2 { //nolint:gomnd
+	if len(h) <= 2 || len(h[0]) < 2 || len(h[1]) < 2 { //nolint:gomnd
 		return nil, fmt.Errorf("not enough data for stringToG2")
 	}
 	h = h[:2]
@@ -581,19 +527,24 @@ func ProofToJSONHex(p *types.Proof) ([]byte, error) {
 
 // ParseWitnessBin parses binary file representation of the Witness into the Witness struct
 func ParseWitnessBin(f *os.File) (types.Witness, error) {
+	return ReadWitnessBin(f)
+}
+
+// ReadWitnessBin reads the binary representation of the Witness into the
+// Witness struct
+func ReadWitnessBin(rd io.Reader) (types.Witness, error) {
 	var w types.Witness
-	r := bufio.NewReader(f)
+	r := bufio.NewReader(rd)
 	for {
 		b := make([]byte, 32)
-		n, err := r.Read(b)
+		n, err := io.ReadFull(r, b)
 		if err == io.EOF {
 			return w, nil
+		} else if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("error on value format, expected 32 bytes, got %v", n)
 		} else if err != nil {
 			return nil, err
 		}
-		if n != 32 { //nolint:gomnd
-			return nil, fmt.Errorf("error on value format, expected 32 bytes, got %v", n)
-		}
 		w = append(w, new(big.Int).SetBytes(swapEndianness(b[0:32])))
 	}
 }
@@ -616,13 +567,77 @@ func readNBytes(r io.Reader, n int) ([]byte, error) {
 	return b, nil
 }
 
+// readSections reads the binary files of circom and snarkjs, made of the
+// magic, the version and the sections, each one as its type, its size and
+// its data. The data of the known sections is returned by type, and the
+// unknown ones are skipped.
+func readSections(r io.Reader, magic string, version uint32,
+	known ...uint32) (map[uint32][]byte, error) {
+	b, err := readNBytes(r, 12) //nolint:gomnd
+	if err != nil {
+		return nil, err
+	}
+	if string(b[:4]) != magic {
+		return nil, fmt.Errorf("invalid %s magic: %q", magic, b[:4])
+	}
+	if v := binary.LittleEndian.Uint32(b[4:8]); v != version {
+		return nil, fmt.Errorf("unsupported %s version: %d", magic, v)
+	}
+	nSections := int(binary.LittleEndian.Uint32(b[8:12]))
+
+	sections := make(map[uint32][]byte)
+	for i := 0; i < nSections; i++ {
+		b, err = readNBytes(r, 12) //nolint:gomnd
+		if err != nil {
+			return nil, err
+		}
+		sType := binary.LittleEndian.Uint32(b[:4])
+		sSize := binary.LittleEndian.Uint64(b[4:12])
+		if _, ok := sections[sType]; ok {
+			return nil, fmt.Errorf("duplicated %s section: %d", magic, sType)
+		}
+		isKnown := false
+		for _, k := range known {
+			isKnown = isKnown || k == sType
+		}
+		if !isKnown {
+			if _, err := io.CopyN(io.Discard, r, int64(sSize)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		data := new(bytes.Buffer)
+		if _, err := io.CopyN(data, r, int64(sSize)); err != nil {
+			return nil, err
+		}
+		sections[sType] = data.Bytes()
+	}
+	return sections, nil
+}
+
+// binSection encodes the section of the binary files of circom and snarkjs
+// as its type, its size and its data
+func binSection(sType uint32, data []byte) []byte {
+	b := make([]byte, 12, 12+len(data)) //nolint:gomnd
+	binary.LittleEndian.PutUint32(b[:4], sType)
+	binary.LittleEndian.PutUint64(b[4:], uint64(len(data)))
+	return append(b, data...)
+}
+
 // ParsePkBin parses binary file representation of the ProvingKey into the
 // ProvingKey struct
-//nolint:gocyclo // TODO WIP
 func ParsePkBin(f *os.File) (*types.Pk, error) {
+	return ReadPkBin(f)
+}
+
+// ReadPkBin reads the binary representation of the ProvingKey into the
+// ProvingKey struct
+//
+//nolint:gocyclo // TODO WIP
+func ReadPkBin(rd io.Reader) (*types.Pk, error) {
 	o := 0
 	var pk types.Pk
-	r := bufio.NewReader(f)
+	r := bufio.NewReader(rd)
 
 	b, err := readNBytes(r, 12)
 	if err != nil {
@@ -989,7 +1004,8 @@ func sortedKeys(m map[int]*big.Int) []int {
 
 // PkToGoBin converts the ProvingKey (*types.Pk) into binary format defined by
 // go-snark.  PkGoBin is a own go-snark binary format that allows to go faster
-// when parsing.
+// when parsing. This is the v1 format, without header nor integrity check,
+// see PkToGoBinV2.
 // nolint:gomnd
 func PkToGoBin(pk *types.Pk) ([]byte, error) {
 	var r []byte
@@ -1093,6 +1109,10 @@ func PkToGoBin(pk *types.Pk) ([]byte, error) {
 		r = append(r, pb1[:]...)
 		o += 64
 	}
+	if uint64(o) > math.MaxUint32 {
+		return nil, fmt.Errorf("proving key of %d bytes does not fit in the go bin"+
+			" v1 uint32 offsets, use PkToGoBinV2", o)
+	}
 
 	return r[:], nil
 }
@@ -1100,11 +1120,25 @@ func PkToGoBin(pk *types.Pk) ([]byte, error) {
 // ParsePkGoBin parses go-snark binary file representation of the ProvingKey
 // into ProvingKey struct (*types.Pk).  PkGoBin is a own go-snark binary format
 // that allows to go faster when parsing.
-//nolint:gocyclo // TODO WIP
 func ParsePkGoBin(f *os.File) (*types.Pk, error) {
+	return ReadPkGoBin(f)
+}
+
+// ReadPkGoBin reads the go-snark binary representation of the ProvingKey, in
+// the v1 format of PkToGoBin or the v2 format of PkToGoBinV2, into the
+// ProvingKey struct
+func ReadPkGoBin(rd io.Reader) (*types.Pk, error) {
+	r := bufio.NewReader(rd)
+	if isGoBinV2(r) {
+		return readPkGoBinV2(r)
+	}
+	return readPkGoBinV1(r)
+}
+
+//nolint:gocyclo // TODO WIP
+func readPkGoBinV1(r *bufio.Reader) (*types.Pk, error) {
 	o := 0
 	var pk types.Pk
-	r := bufio.NewReader(f)
 
 	b, err := readNBytes(r, 12)
 	if err != nil {
@@ -1,13 +1,23 @@
 package parsers
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/prover"
+	"github.com/vocdoni/go-snark/setup"
 	"github.com/vocdoni/go-snark/types"
 )
 
@@ -348,3 +358,238 @@ func BenchmarkParsePk(b *testing.B) {
 	// benchmarkParsePk(b, "circuit10k")
 	// benchmarkParsePk(b, "circuit20k")
 }
+
+// g1Strings returns the snarkjs json representation of the G1 point
+func g1Strings(p *bn256.G1) []string {
+	m := p.Marshal()
+	if new(big.Int).SetBytes(m).Sign() == 0 {
+		return []string{"0", "1", "0"}
+	}
+	return []string{new(big.Int).SetBytes(m[:32]).String(),
+		new(big.Int).SetBytes(m[32:]).String(), "1"}
+}
+
+// g2Strings returns the snarkjs json representation of the G2 point
+func g2Strings(p *bn256.G2) [][]string {
+	m := p.Marshal()
+	if new(big.Int).SetBytes(m).Sign() == 0 {
+		return [][]string{{"0", "0"}, {"1", "0"}, {"0", "0"}}
+	}
+	c := make([]string, 4)
+	for i := range c {
+		c[i] = new(big.Int).SetBytes(m[i*32 : (i+1)*32]).String()
+	}
+	return [][]string{{c[1], c[0]}, {c[3], c[2]}, {"1", "0"}}
+}
+
+func g1ArrayStrings(points []*bn256.G1) [][]string {
+	s := make([][]string, len(points))
+	for i := range points {
+		s[i] = g1Strings(points[i])
+	}
+	return s
+}
+
+func polsStrings(pols []map[int]*big.Int) []map[string]string {
+	s := make([]map[string]string, len(pols))
+	for i := range pols {
+		s[i] = make(map[string]string)
+		for j, v := range pols[i] {
+			s[i][big.NewInt(int64(j)).String()] = v.String()
+		}
+	}
+	return s
+}
+
+// pkToJSON encodes the ProvingKey in the snarkjs proving_key.json format
+func pkToJSON(t testing.TB, pk *types.Pk) []byte {
+	ps := PkString{
+		A:          g1ArrayStrings(pk.A),
+		B1:         g1ArrayStrings(pk.B1),
+		C:          g1ArrayStrings(pk.C),
+		NVars:      pk.NVars,
+		NPublic:    pk.NPublic,
+		VkAlpha1:   g1Strings(pk.VkAlpha1),
+		VkDelta1:   g1Strings(pk.VkDelta1),
+		VkBeta1:    g1Strings(pk.VkBeta1),
+		VkBeta2:    g2Strings(pk.VkBeta2),
+		VkDelta2:   g2Strings(pk.VkDelta2),
+		HExps:      g1ArrayStrings(pk.HExps),
+		DomainSize: pk.DomainSize,
+		PolsA:      polsStrings(pk.PolsA),
+		PolsB:      polsStrings(pk.PolsB),
+	}
+	for _, p := range pk.B2 {
+		ps.B2 = append(ps.B2, g2Strings(p))
+	}
+	b, err := json.Marshal(ps)
+	require.Nil(t, err)
+	return b
+}
+
+// vkToJSON encodes the VerificationKey in the snarkjs verification_key.json
+// format
+func vkToJSON(t testing.TB, vk *types.Vk) []byte {
+	b, err := json.Marshal(VkString{
+		Alpha: g1Strings(vk.Alpha),
+		Beta:  g2Strings(vk.Beta),
+		Gamma: g2Strings(vk.Gamma),
+		Delta: g2Strings(vk.Delta),
+		IC:    g1ArrayStrings(vk.IC),
+	})
+	require.Nil(t, err)
+	return b
+}
+
+func TestReadJSON(t *testing.T) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	expected, err := PkToGoBin(pk)
+	require.Nil(t, err)
+
+	// byte by byte reads
+	pkJSON := pkToJSON(t, pk)
+	pk2, err := ReadPk(iotest.OneByteReader(bytes.NewReader(pkJSON)))
+	require.Nil(t, err)
+	pkGoBin, err := PkToGoBin(pk2)
+	require.Nil(t, err)
+	assert.Equal(t, expected, pkGoBin)
+
+	vkJSON := vkToJSON(t, vk)
+	vk2, err := ReadVk(iotest.OneByteReader(bytes.NewReader(vkJSON)))
+	require.Nil(t, err)
+	assert.Equal(t, vk.Alpha.Marshal(), vk2.Alpha.Marshal())
+	assert.Equal(t, vk.Gamma.Marshal(), vk2.Gamma.Marshal())
+	assert.Equal(t, len(vk.IC), len(vk2.IC))
+
+	// compressed stream
+	w := testutil.CubicWitness(3)
+	proof, _, err := prover.GenerateProof(pk, w)
+	require.Nil(t, err)
+	proofJSON, err := ProofToJSON(proof)
+	require.Nil(t, err)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err = zw.Write(proofJSON)
+	require.Nil(t, err)
+	require.Nil(t, zw.Close())
+	zr, err := gzip.NewReader(&gz)
+	require.Nil(t, err)
+	proof2, err := ReadProof(zr)
+	require.Nil(t, err)
+	assert.Equal(t, proof.A.Marshal(), proof2.A.Marshal())
+	assert.Equal(t, proof.B.Marshal(), proof2.B.Marshal())
+	assert.Equal(t, proof.C.Marshal(), proof2.C.Marshal())
+
+	public, err := ReadPublicSignals(strings.NewReader(` ["35"]` + "\n"))
+	require.Nil(t, err)
+	assert.Equal(t, []*big.Int{big.NewInt(35)}, public)
+	w2, err := ReadWitness(strings.NewReader(`["1","35","3","9","27","30"]`))
+	require.Nil(t, err)
+	assert.Equal(t, ArrayBigIntToString(w), ArrayBigIntToString(w2))
+
+	// data after the json value, or no value, as json.Unmarshal
+	_, err = ReadPublicSignals(strings.NewReader(`["35"] ["36"]`))
+	assert.NotNil(t, err)
+	_, err = ParseVk(append(vkJSON, '}'))
+	assert.NotNil(t, err)
+	_, err = ParseVk(nil)
+	assert.NotNil(t, err)
+	_, err = ReadPk(bytes.NewReader(pkJSON[:len(pkJSON)-1]))
+	assert.NotNil(t, err)
+}
+
+func TestReadPkStream(t *testing.T) {
+	pk, _, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	expected, err := PkToGoBin(pk)
+	require.Nil(t, err)
+	pkJSON := pkToJSON(t, pk)
+	readPk := func(m map[string]interface{}) (*types.Pk, error) {
+		b, err := json.Marshal(m)
+		require.Nil(t, err)
+		return ParsePk(b)
+	}
+	fields := func() map[string]interface{} {
+		var m map[string]interface{}
+		require.Nil(t, json.Unmarshal(pkJSON, &m))
+		return m
+	}
+
+	// the fields in any order, with unknown fields ignored
+	m := fields()
+	m["protocol"] = "groth"
+	m["polsC"] = []map[string]string{{"0": "1"}}
+	pk2, err := readPk(m)
+	require.Nil(t, err)
+	pkGoBin, err := PkToGoBin(pk2)
+	require.Nil(t, err)
+	assert.Equal(t, expected, pkGoBin)
+
+	// the points in hexadecimal
+	m = fields()
+	p := pk.VkAlpha1.Marshal()
+	m["vk_alpha_1"] = []string{"0x" + hex.EncodeToString(p[:32]),
+		"0x" + hex.EncodeToString(p[32:]), "0x01"}
+	pk2, err = readPk(m)
+	require.Nil(t, err)
+	assert.Equal(t, pk.VkAlpha1.Marshal(), pk2.VkAlpha1.Marshal())
+
+	// null arrays are empty
+	m = fields()
+	m["polsB"] = nil
+	pk2, err = readPk(m)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(pk2.PolsB))
+
+	// point out of the curve
+	m = fields()
+	a := m["A"].([]interface{})
+	a[len(a)-1] = []string{"1", "3", "1"}
+	_, err = readPk(m)
+	assert.NotNil(t, err)
+
+	m = fields()
+	delete(m, "vk_delta_2")
+	_, err = readPk(m)
+	assert.NotNil(t, err)
+
+	m = fields()
+	m["nVars"] = 1.5
+	_, err = readPk(m)
+	assert.NotNil(t, err)
+
+	m = fields()
+	m["polsA"] = []map[string]string{{"x": "1"}}
+	_, err = readPk(m)
+	assert.NotNil(t, err)
+
+	m = fields()
+	m["B2"] = []string{"1"}
+	_, err = readPk(m)
+	assert.NotNil(t, err)
+
+	_, err = ParsePk([]byte(`[]`))
+	assert.NotNil(t, err)
+
+	// truncated data
+	for i := 0; i < len(pkJSON); i += len(pkJSON) / 50 {
+		_, err = ParsePk(pkJSON[:i])
+		assert.NotNil(t, err)
+	}
+}
+
+func TestReadWitnessBin(t *testing.T) {
+	w := testutil.CubicWitness(3)
+	var b []byte
+	for _, v := range w {
+		b = append(b, swapEndianness(addPadding32(v.Bytes()))...)
+	}
+	// short reads do not split the values
+	w2, err := ReadWitnessBin(iotest.HalfReader(bytes.NewReader(b)))
+	require.Nil(t, err)
+	assert.Equal(t, ArrayBigIntToString(w), ArrayBigIntToString(w2))
+
+	_, err = ReadWitnessBin(bytes.NewReader(b[:len(b)-1]))
+	assert.NotNil(t, err)
+}
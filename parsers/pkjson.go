@@ -0,0 +1,324 @@
+package parsers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"runtime"
+	"strconv"
+	"sync"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// pkDecoder decodes the json ProvingKey token by token, converting each point
+// and coefficient into the Pk as it is read, so the string representation of
+// the whole ProvingKey is never held in memory. The conversion of the points
+// to bn256 is done by runtime.NumCPU() workers.
+type pkDecoder struct {
+	d    *json.Decoder
+	jobs chan func() error
+	wg   sync.WaitGroup
+
+	mu  sync.Mutex
+	err error
+}
+
+// decodePk decodes the json ProvingKey of r into the Pk struct
+func decodePk(r io.Reader) (*types.Pk, error) {
+	pd := &pkDecoder{
+		d:    json.NewDecoder(r),
+		jobs: make(chan func() error, runtime.NumCPU()),
+	}
+	pd.d.UseNumber()
+	numcpu := runtime.NumCPU()
+	pd.wg.Add(numcpu)
+	for i := 0; i < numcpu; i++ {
+		go func() {
+			for job := range pd.jobs {
+				if err := job(); err != nil {
+					pd.setErr(err)
+				}
+			}
+			pd.wg.Done()
+		}()
+	}
+
+	pk, err := pd.decode()
+	close(pd.jobs)
+	pd.wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if err := pd.getErr(); err != nil {
+		return nil, err
+	}
+	return pk, nil
+}
+
+func (pd *pkDecoder) setErr(err error) {
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+	if pd.err == nil {
+		pd.err = err
+	}
+}
+
+func (pd *pkDecoder) getErr() error {
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+	return pd.err
+}
+
+//nolint:gocyclo
+func (pd *pkDecoder) decode() (*types.Pk, error) {
+	var pk types.Pk
+	if err := pd.delim('{'); err != nil {
+		return nil, err
+	}
+	for pd.d.More() {
+		key, err := pd.string()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "A":
+			pk.A, err = pd.g1Array()
+		case "B1":
+			pk.B1, err = pd.g1Array()
+		case "B2":
+			pk.B2, err = pd.g2Array()
+		case "C":
+			pk.C, err = pd.g1Array()
+		case "hExps":
+			pk.HExps, err = pd.g1Array()
+		case "vk_alpha_1":
+			pk.VkAlpha1, err = pd.g1()
+		case "vk_beta_1":
+			pk.VkBeta1, err = pd.g1()
+		case "vk_delta_1":
+			pk.VkDelta1, err = pd.g1()
+		case "vk_beta_2":
+			pk.VkBeta2, err = pd.g2()
+		case "vk_delta_2":
+			pk.VkDelta2, err = pd.g2()
+		case "nVars":
+			pk.NVars, err = pd.int()
+		case "nPublic":
+			pk.NPublic, err = pd.int()
+		case "domainSize":
+			pk.DomainSize, err = pd.int()
+		case "polsA":
+			pk.PolsA, err = pd.pols()
+		case "polsB":
+			pk.PolsB, err = pd.pols()
+		default:
+			// unknown fields are ignored, as by json.Unmarshal
+			var v json.RawMessage
+			err = pd.d.Decode(&v)
+		}
+		if err != nil {
+			return nil, err
+		}
+		// stop reading once a point has failed to be converted
+		if err := pd.getErr(); err != nil {
+			return nil, err
+		}
+	}
+	if err := pd.delim('}'); err != nil {
+		return nil, err
+	}
+	if _, err := pd.d.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid data after the json value")
+	}
+
+	for _, p := range []struct {
+		key   string
+		found bool
+	}{
+		{"vk_alpha_1", pk.VkAlpha1 != nil},
+		{"vk_beta_1", pk.VkBeta1 != nil},
+		{"vk_delta_1", pk.VkDelta1 != nil},
+		{"vk_beta_2", pk.VkBeta2 != nil},
+		{"vk_delta_2", pk.VkDelta2 != nil},
+	} {
+		if !p.found {
+			return nil, fmt.Errorf("%s not found in the proving key json", p.key)
+		}
+	}
+	return &pk, nil
+}
+
+// token returns the next json token, being an unexpected EOF the end of the
+// data before the end of the value
+func (pd *pkDecoder) token() (json.Token, error) {
+	t, err := pd.d.Token()
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return t, err
+}
+
+func (pd *pkDecoder) delim(delim json.Delim) error {
+	t, err := pd.token()
+	if err != nil {
+		return err
+	}
+	if d, ok := t.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("invalid proving key json: expected %s, found %v", delim, t)
+	}
+	return nil
+}
+
+func (pd *pkDecoder) string() (string, error) {
+	t, err := pd.token()
+	if err != nil {
+		return "", err
+	}
+	s, ok := t.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid proving key json: expected string, found %v", t)
+	}
+	return s, nil
+}
+
+func (pd *pkDecoder) int() (int, error) {
+	t, err := pd.token()
+	if err != nil {
+		return 0, err
+	}
+	n, ok := t.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("invalid proving key json: expected number, found %v", t)
+	}
+	return strconv.Atoi(n.String())
+}
+
+// array calls f for each element of the json array, being null an empty
+// array
+func (pd *pkDecoder) array(f func() error) error {
+	t, err := pd.token()
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return nil
+	}
+	if d, ok := t.(json.Delim); !ok || d != '[' {
+		return fmt.Errorf("invalid proving key json: expected [, found %v", t)
+	}
+	for pd.d.More() {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return pd.delim(']')
+}
+
+func (pd *pkDecoder) strings() ([]string, error) {
+	var s []string
+	err := pd.array(func() error {
+		si, err := pd.string()
+		s = append(s, si)
+		return err
+	})
+	return s, err
+}
+
+// g1 reads the coordinates of a G1 point, returning the point that is set
+// by a worker once converted
+func (pd *pkDecoder) g1() (*bn256.G1, error) {
+	s, err := pd.strings()
+	if err != nil {
+		return nil, err
+	}
+	p := new(bn256.G1)
+	pd.jobs <- func() error {
+		q, err := stringToG1(s)
+		if err != nil {
+			return err
+		}
+		p.Set(q)
+		return nil
+	}
+	return p, nil
+}
+
+// g2 reads the coordinates of a G2 point, returning the point that is set
+// by a worker once converted
+func (pd *pkDecoder) g2() (*bn256.G2, error) {
+	var s [][]string
+	err := pd.array(func() error {
+		si, err := pd.strings()
+		s = append(s, si)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	p := new(bn256.G2)
+	pd.jobs <- func() error {
+		q, err := stringToG2(s)
+		if err != nil {
+			return err
+		}
+		p.Set(q)
+		return nil
+	}
+	return p, nil
+}
+
+func (pd *pkDecoder) g1Array() ([]*bn256.G1, error) {
+	var o []*bn256.G1
+	err := pd.array(func() error {
+		p, err := pd.g1()
+		o = append(o, p)
+		return err
+	})
+	return o, err
+}
+
+func (pd *pkDecoder) g2Array() ([]*bn256.G2, error) {
+	var o []*bn256.G2
+	err := pd.array(func() error {
+		p, err := pd.g2()
+		o = append(o, p)
+		return err
+	})
+	return o, err
+}
+
+// pols reads the polynomials, being each one an object from the index of the
+// coefficient to its value
+func (pd *pkDecoder) pols() ([]map[int]*big.Int, error) {
+	var o []map[int]*big.Int
+	err := pd.array(func() error {
+		if err := pd.delim('{'); err != nil {
+			return err
+		}
+		oi := make(map[int]*big.Int)
+		for pd.d.More() {
+			j, err := pd.string()
+			if err != nil {
+				return err
+			}
+			jInt, err := strconv.Atoi(j)
+			if err != nil {
+				return err
+			}
+			v, err := pd.string()
+			if err != nil {
+				return err
+			}
+			oi[jInt], err = stringToBigInt(v)
+			if err != nil {
+				return err
+			}
+		}
+		o = append(o, oi)
+		return pd.delim('}')
+	})
+	return o, err
+}
@@ -0,0 +1,156 @@
+package parsers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/vocdoni/go-snark/types"
+)
+
+const (
+	r1csMagic                = "r1cs"
+	r1csVersion              = 1
+	r1csSectionHeader        = 1
+	r1csSectionConstraints   = 2
+	r1csSectionWireToLabel   = 3
+	r1csHeaderFixedFieldSize = 28 // nWires, nPubOut, nPubIn, nPrvIn, nLabels(u64), nConstraints
+)
+
+// ParseR1CS parses the circom binary file representation of the R1CS
+// (.r1cs) into the R1CS struct
+func ParseR1CS(f *os.File) (*types.R1CS, error) {
+	return ReadR1CS(f)
+}
+
+// ReadR1CS reads the circom binary representation of the R1CS into the R1CS
+// struct
+func ReadR1CS(rd io.Reader) (*types.R1CS, error) {
+	r := bufio.NewReader(rd)
+
+	// sections can be in any order, and the constraints section needs the
+	// header to be parsed, so keep them until all of them are read. Unknown
+	// sections (such as custom gates) are skipped.
+	sections, err := readSections(r, r1csMagic, r1csVersion, r1csSectionHeader,
+		r1csSectionConstraints, r1csSectionWireToLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	header, ok := sections[r1csSectionHeader]
+	if !ok {
+		return nil, fmt.Errorf("r1cs header section not found")
+	}
+	r1cs, n8, nConstraints, err := parseR1CSHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	constraints, ok := sections[r1csSectionConstraints]
+	if !ok {
+		return nil, fmt.Errorf("r1cs constraints section not found")
+	}
+	r1cs.Constraints, err = parseR1CSConstraints(bytes.NewReader(constraints), n8,
+		nConstraints, r1cs.NVars)
+	if err != nil {
+		return nil, err
+	}
+
+	if w2l, ok := sections[r1csSectionWireToLabel]; ok {
+		if len(w2l) != 8*r1cs.NVars { //nolint:gomnd
+			return nil, fmt.Errorf("unexpected r1cs wire to label section size,"+
+				" expected: %v, actual: %v", 8*r1cs.NVars, len(w2l))
+		}
+		r1cs.WireToLabel = make([]uint64, r1cs.NVars)
+		for i := 0; i < r1cs.NVars; i++ {
+			r1cs.WireToLabel[i] = binary.LittleEndian.Uint64(w2l[i*8 : (i+1)*8])
+		}
+	}
+
+	return r1cs, nil
+}
+
+func parseR1CSHeader(b []byte) (*types.R1CS, int, int, error) {
+	if len(b) < 4 { //nolint:gomnd
+		return nil, 0, 0, fmt.Errorf("r1cs header section too short")
+	}
+	n8 := int(binary.LittleEndian.Uint32(b[:4]))
+	if len(b) != 4+n8+r1csHeaderFixedFieldSize {
+		return nil, 0, 0, fmt.Errorf("unexpected r1cs header section size,"+
+			" expected: %v, actual: %v", 4+n8+r1csHeaderFixedFieldSize, len(b))
+	}
+	var r1cs types.R1CS
+	r1cs.Prime = new(big.Int).SetBytes(swapEndianness(b[4 : 4+n8]))
+	if n8 == 0 || r1cs.Prime.Cmp(types.R) != 0 {
+		return nil, 0, 0, fmt.Errorf("r1cs prime is not the bn256 scalar field: %s",
+			r1cs.Prime)
+	}
+	b = b[4+n8:]
+	r1cs.NVars = int(binary.LittleEndian.Uint32(b[:4]))
+	r1cs.NOutputs = int(binary.LittleEndian.Uint32(b[4:8]))
+	r1cs.NPubInputs = int(binary.LittleEndian.Uint32(b[8:12]))
+	r1cs.NPrvInputs = int(binary.LittleEndian.Uint32(b[12:16]))
+	r1cs.NLabels = int(binary.LittleEndian.Uint64(b[16:24]))
+	nConstraints := int(binary.LittleEndian.Uint32(b[24:28]))
+	r1cs.NPublic = r1cs.NOutputs + r1cs.NPubInputs
+	return &r1cs, n8, nConstraints, nil
+}
+
+// parseR1CSConstraints parses the constraints section, checking the number
+// of constraints and factors against its size before allocating them
+func parseR1CSConstraints(r *bytes.Reader, n8, nConstraints, nVars int) ([]types.Constraint,
+	error) {
+	// each constraint has at least the 3 numbers of factors
+	if nConstraints > r.Len()/12 { //nolint:gomnd
+		return nil, fmt.Errorf("r1cs constraints section too short for %d constraints",
+			nConstraints)
+	}
+	constraints := make([]types.Constraint, nConstraints)
+	for i := 0; i < nConstraints; i++ {
+		lcs := make([]types.LinearCombination, 3) //nolint:gomnd
+		for j := range lcs {
+			b, err := readNBytes(r, 4) //nolint:gomnd
+			if err != nil {
+				return nil, err
+			}
+			nFactors := int(binary.LittleEndian.Uint32(b))
+			if nFactors > r.Len()/(4+n8) {
+				return nil, fmt.Errorf("r1cs constraint %d too short for %d factors",
+					i, nFactors)
+			}
+			lc := make(types.LinearCombination, nFactors)
+			for k := 0; k < nFactors; k++ {
+				b, err = readNBytes(r, 4+n8)
+				if err != nil {
+					return nil, err
+				}
+				wire := int(binary.LittleEndian.Uint32(b[:4]))
+				if wire >= nVars {
+					return nil, fmt.Errorf("constraint %d references wire %d out of range",
+						i, wire)
+				}
+				if _, ok := lc[wire]; ok {
+					return nil, fmt.Errorf("constraint %d references wire %d twice in"+
+						" a linear combination", i, wire)
+				}
+				v := new(big.Int).SetBytes(swapEndianness(b[4:]))
+				if v.Cmp(types.R) >= 0 {
+					return nil, fmt.Errorf("constraint %d has the coefficient of wire %d"+
+						" out of the field", i, wire)
+				}
+				lc[wire] = v
+			}
+			lcs[j] = lc
+		}
+		constraints[i] = types.Constraint{A: lcs[0], B: lcs[1], C: lcs[2]}
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("unexpected r1cs constraints section size,"+
+			" %d bytes after the constraints", r.Len())
+	}
+	return constraints, nil
+}
@@ -0,0 +1,171 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// r1csToBin encodes the given R1CS in the circom .r1cs format, writing the
+// constraints section before the header to check that the sections order is
+// not assumed by the parser
+func r1csToBin(r1cs *types.R1CS) []byte {
+	var b [8]byte
+	var header []byte
+	binary.LittleEndian.PutUint32(b[:4], 32) //nolint:gomnd
+	header = append(header, b[:4]...)
+	header = append(header, swapEndianness(addPadding32(r1cs.Prime.Bytes()))...)
+	for _, v := range []int{r1cs.NVars, r1cs.NOutputs, r1cs.NPubInputs, r1cs.NPrvInputs} {
+		binary.LittleEndian.PutUint32(b[:4], uint32(v))
+		header = append(header, b[:4]...)
+	}
+	binary.LittleEndian.PutUint64(b[:], uint64(r1cs.NLabels))
+	header = append(header, b[:]...)
+	binary.LittleEndian.PutUint32(b[:4], uint32(len(r1cs.Constraints)))
+	header = append(header, b[:4]...)
+
+	var constraints []byte
+	for _, c := range r1cs.Constraints {
+		for _, lc := range []types.LinearCombination{c.A, c.B, c.C} {
+			binary.LittleEndian.PutUint32(b[:4], uint32(len(lc)))
+			constraints = append(constraints, b[:4]...)
+			for _, wire := range sortedKeys(lc) {
+				binary.LittleEndian.PutUint32(b[:4], uint32(wire))
+				constraints = append(constraints, b[:4]...)
+				constraints = append(constraints, swapEndianness(addPadding32(lc[wire].Bytes()))...)
+			}
+		}
+	}
+
+	var w2l []byte
+	for _, l := range r1cs.WireToLabel {
+		binary.LittleEndian.PutUint64(b[:], l)
+		w2l = append(w2l, b[:]...)
+	}
+
+	r := []byte("r1cs")
+	binary.LittleEndian.PutUint32(b[:4], 1)
+	r = append(r, b[:4]...)
+	binary.LittleEndian.PutUint32(b[:4], 3) //nolint:gomnd
+	r = append(r, b[:4]...)
+	r = append(r, binSection(2, constraints)...) //nolint:gomnd
+	r = append(r, binSection(1, header)...)
+	r = append(r, binSection(3, w2l)...) //nolint:gomnd
+	return r
+}
+
+func testR1CS() *types.R1CS {
+	one := big.NewInt(1)
+	minusOne := new(big.Int).Sub(types.R, one)
+	return &types.R1CS{
+		Prime:      types.R,
+		NVars:      5,
+		NOutputs:   1,
+		NPubInputs: 1,
+		NPrvInputs: 1,
+		NPublic:    2,
+		NLabels:    7,
+		Constraints: []types.Constraint{
+			{
+				A: types.LinearCombination{2: one},
+				B: types.LinearCombination{3: one},
+				C: types.LinearCombination{4: one},
+			},
+			{
+				A: types.LinearCombination{4: one, 0: big.NewInt(3)},
+				B: types.LinearCombination{0: one},
+				C: types.LinearCombination{1: one, 2: minusOne},
+			},
+		},
+		WireToLabel: []uint64{0, 1, 2, 3, 6},
+	}
+}
+
+func TestParseR1CS(t *testing.T) {
+	expected := testR1CS()
+	path := filepath.Join(t.TempDir(), "circuit.r1cs")
+	err := ioutil.WriteFile(path, r1csToBin(expected), 0600)
+	require.Nil(t, err)
+
+	f, err := os.Open(path) //nolint:gosec
+	require.Nil(t, err)
+	defer f.Close() //nolint:errcheck,gosec
+	r1cs, err := ParseR1CS(f)
+	require.Nil(t, err)
+	assert.Equal(t, expected, r1cs)
+
+	r1cs, err = ReadR1CS(iotest.OneByteReader(bytes.NewReader(r1csToBin(expected))))
+	require.Nil(t, err)
+	assert.Equal(t, expected, r1cs)
+}
+
+func TestParseR1CSInvalid(t *testing.T) {
+	dir := t.TempDir()
+	parse := func(b []byte) error {
+		path := filepath.Join(dir, "circuit.r1cs")
+		require.Nil(t, ioutil.WriteFile(path, b, 0600))
+		f, err := os.Open(path) //nolint:gosec
+		require.Nil(t, err)
+		defer f.Close() //nolint:errcheck,gosec
+		_, err = ParseR1CS(f)
+		return err
+	}
+
+	b := r1csToBin(testR1CS())
+	assert.Nil(t, parse(b))
+
+	// wrong magic
+	bad := append([]byte("r1cz"), b[4:]...)
+	assert.NotNil(t, parse(bad))
+
+	// truncated file
+	assert.NotNil(t, parse(b[:len(b)-10]))
+
+	// wire out of range
+	r1cs := testR1CS()
+	r1cs.Constraints[0].A[5] = big.NewInt(1)
+	assert.NotNil(t, parse(r1csToBin(r1cs)))
+
+	// the constraints section is first, followed by the header section
+	constraints := 12 + 12
+	header := constraints + int(binary.LittleEndian.Uint64(b[constraints-8:constraints])) + 12
+
+	// prime not being the bn256 scalar field
+	bad = append([]byte{}, b...)
+	bad[header+4]++
+	assert.NotNil(t, parse(bad))
+
+	// number of constraints and factors not fitting in the section, which
+	// must not be allocated
+	bad = append([]byte{}, b...)
+	binary.LittleEndian.PutUint32(bad[header+4+32+24:], 0xffffffff)
+	assert.NotNil(t, parse(bad))
+	bad = append([]byte{}, b...)
+	binary.LittleEndian.PutUint32(bad[constraints:], 0xffffffff)
+	assert.NotNil(t, parse(bad))
+
+	// wire repeated in a linear combination, whose coefficients would be
+	// overwritten
+	bad = append([]byte{}, b...)
+	binary.LittleEndian.PutUint32(bad[constraints+120+4+36:], 0)
+	assert.NotNil(t, parse(bad))
+
+	// coefficient not in the field
+	bad = append([]byte{}, b...)
+	copy(bad[constraints+8:constraints+40], swapEndianness(types.R.Bytes()))
+	assert.NotNil(t, parse(bad))
+
+	// data after the constraints
+	bad = append([]byte{}, b...)
+	binary.LittleEndian.PutUint32(bad[header+4+32+24:], 1)
+	assert.NotNil(t, parse(bad))
+}
@@ -0,0 +1,105 @@
+package parsers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/vocdoni/go-snark/types"
+)
+
+const (
+	wtnsMagic         = "wtns"
+	wtnsVersion       = 2
+	wtnsSectionHeader = 1
+	wtnsSectionData   = 2
+	wtnsN8            = 32
+)
+
+// ParseWtns parses the circom binary file representation of the Witness
+// (.wtns) into the Witness struct, checking that its field is the bn256
+// scalar field. When pk is not nil, the number of values must be the
+// ProvingKey NVars.
+func ParseWtns(f *os.File, pk *types.Pk) (types.Witness, error) {
+	return ReadWtns(f, pk)
+}
+
+// ReadWtns reads the circom binary representation of the Witness into the
+// Witness struct, as ParseWtns
+func ReadWtns(rd io.Reader, pk *types.Pk) (types.Witness, error) {
+	r := bufio.NewReader(rd)
+
+	sections, err := readSections(r, wtnsMagic, wtnsVersion, wtnsSectionHeader,
+		wtnsSectionData)
+	if err != nil {
+		return nil, err
+	}
+
+	header, ok := sections[wtnsSectionHeader]
+	if !ok {
+		return nil, fmt.Errorf("wtns header section not found")
+	}
+	// n8, prime, nWitness
+	if len(header) != 4+wtnsN8+4 {
+		return nil, fmt.Errorf("unexpected wtns header section size,"+
+			" expected: %v, actual: %v", 4+wtnsN8+4, len(header))
+	}
+	n8 := int(binary.LittleEndian.Uint32(header[:4]))
+	prime := new(big.Int).SetBytes(swapEndianness(header[4 : 4+wtnsN8]))
+	if n8 != wtnsN8 || prime.Cmp(types.R) != 0 {
+		return nil, fmt.Errorf("wtns prime is not the bn256 scalar field: %s", prime)
+	}
+	nWitness := int(binary.LittleEndian.Uint32(header[4+wtnsN8:]))
+	if pk != nil && nWitness != pk.NVars {
+		return nil, fmt.Errorf("wtns has %d values, the proving key expects %d",
+			nWitness, pk.NVars)
+	}
+
+	data, ok := sections[wtnsSectionData]
+	if !ok {
+		return nil, fmt.Errorf("wtns data section not found")
+	}
+	if len(data) != nWitness*wtnsN8 {
+		return nil, fmt.Errorf("unexpected wtns data section size,"+
+			" expected: %v, actual: %v", nWitness*wtnsN8, len(data))
+	}
+	w := make(types.Witness, nWitness)
+	for i := range w {
+		w[i] = new(big.Int).SetBytes(swapEndianness(data[i*wtnsN8 : (i+1)*wtnsN8]))
+		if w[i].Cmp(types.R) >= 0 {
+			return nil, fmt.Errorf("wtns value %d is not in the field", i)
+		}
+	}
+	return w, nil
+}
+
+// WitnessToWtns converts the Witness into the circom binary format (.wtns)
+func WitnessToWtns(w types.Witness) ([]byte, error) {
+	var b [4]byte
+	header := make([]byte, 0, 4+wtnsN8+4)
+	binary.LittleEndian.PutUint32(b[:], wtnsN8)
+	header = append(header, b[:]...)
+	header = append(header, swapEndianness(addPadding32(types.R.Bytes()))...)
+	binary.LittleEndian.PutUint32(b[:], uint32(len(w)))
+	header = append(header, b[:]...)
+
+	data := make([]byte, 0, len(w)*wtnsN8)
+	for i, v := range w {
+		if v == nil || v.Sign() < 0 || v.Cmp(types.R) >= 0 {
+			return nil, fmt.Errorf("witness value %d is not in the field", i)
+		}
+		data = append(data, swapEndianness(addPadding32(v.Bytes()))...)
+	}
+
+	wtns := []byte(wtnsMagic)
+	binary.LittleEndian.PutUint32(b[:], wtnsVersion)
+	wtns = append(wtns, b[:]...)
+	binary.LittleEndian.PutUint32(b[:], 2) //nolint:gomnd
+	wtns = append(wtns, b[:]...)
+	wtns = append(wtns, binSection(wtnsSectionHeader, header)...)
+	wtns = append(wtns, binSection(wtnsSectionData, data)...)
+	return wtns, nil
+}
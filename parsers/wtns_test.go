@@ -0,0 +1,85 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/types"
+)
+
+func parseWtnsBytes(t *testing.T, b []byte, pk *types.Pk) (types.Witness, error) {
+	path := filepath.Join(t.TempDir(), "witness.wtns")
+	require.Nil(t, ioutil.WriteFile(path, b, 0600))
+	f, err := os.Open(path) //nolint:gosec
+	require.Nil(t, err)
+	defer f.Close() //nolint:errcheck,gosec
+	return ParseWtns(f, pk)
+}
+
+func TestWtns(t *testing.T) {
+	w := types.Witness{big.NewInt(1), big.NewInt(35), big.NewInt(3),
+		new(big.Int).Sub(types.R, big.NewInt(1))}
+	b, err := WitnessToWtns(w)
+	require.Nil(t, err)
+
+	// magic, version, number of sections, header and data sections
+	require.Equal(t, 12+12+40+12+4*32, len(b))
+	assert.Equal(t, "wtns", string(b[:4]))
+	assert.Equal(t, uint32(2), binary.LittleEndian.Uint32(b[4:8]))
+	assert.Equal(t, uint32(2), binary.LittleEndian.Uint32(b[8:12]))
+	assert.Equal(t, uint32(1), binary.LittleEndian.Uint32(b[12:16]))
+	assert.Equal(t, uint64(40), binary.LittleEndian.Uint64(b[16:24]))
+	assert.Equal(t, uint32(32), binary.LittleEndian.Uint32(b[24:28]))
+	assert.Equal(t, types.R, new(big.Int).SetBytes(swapEndianness(b[28:60])))
+	assert.Equal(t, uint32(4), binary.LittleEndian.Uint32(b[60:64]))
+	assert.Equal(t, byte(35), b[76+32])
+
+	parsed, err := parseWtnsBytes(t, b, nil)
+	require.Nil(t, err)
+	assert.Equal(t, ArrayBigIntToString(w), ArrayBigIntToString(parsed))
+	parsed, err = parseWtnsBytes(t, b, &types.Pk{NVars: 4})
+	require.Nil(t, err)
+	assert.Equal(t, ArrayBigIntToString(w), ArrayBigIntToString(parsed))
+	parsed, err = ReadWtns(iotest.OneByteReader(bytes.NewReader(b)), nil)
+	require.Nil(t, err)
+	assert.Equal(t, ArrayBigIntToString(w), ArrayBigIntToString(parsed))
+
+	// witness length not matching the proving key
+	_, err = parseWtnsBytes(t, b, &types.Pk{NVars: 5})
+	assert.NotNil(t, err)
+
+	// wrong prime
+	bad := append([]byte{}, b...)
+	bad[28]++
+	_, err = parseWtnsBytes(t, bad, nil)
+	assert.NotNil(t, err)
+
+	// value out of the field
+	bad = append([]byte{}, b...)
+	copy(bad[76+3*32:], swapEndianness(types.R.Bytes()))
+	_, err = parseWtnsBytes(t, bad, nil)
+	assert.NotNil(t, err)
+
+	// unsupported version
+	bad = append([]byte{}, b...)
+	bad[4] = 1
+	_, err = parseWtnsBytes(t, bad, nil)
+	assert.NotNil(t, err)
+
+	// truncated file
+	_, err = parseWtnsBytes(t, b[:len(b)-1], nil)
+	assert.NotNil(t, err)
+
+	_, err = WitnessToWtns(types.Witness{big.NewInt(1), types.R})
+	assert.NotNil(t, err)
+	_, err = WitnessToWtns(types.Witness{big.NewInt(-1)})
+	assert.NotNil(t, err)
+}
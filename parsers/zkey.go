@@ -0,0 +1,380 @@
+package parsers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+	"os"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/internal/parallel"
+	"github.com/vocdoni/go-snark/types"
+)
+
+const (
+	zkeyMagic              = "zkey"
+	zkeyVersion            = 1
+	zkeySectionHeader      = 1
+	zkeySectionGroth16     = 2
+	zkeySectionIC          = 3
+	zkeySectionCoefs       = 4
+	zkeySectionA           = 5
+	zkeySectionB1          = 6
+	zkeySectionB2          = 7
+	zkeySectionC           = 8
+	zkeySectionH           = 9
+	zkeyProtocolGroth16    = 1
+	zkeyN8                 = 32 // size of the elements of both fields
+	zkeyCoefFixedFieldSize = 12 // matrix, constraint, signal
+	// zkeyMaxDomainBits is the maximum domain size, as the conversion of the H
+	// points uses the roots of unity of twice the domain size
+	zkeyMaxDomainBits = 27
+)
+
+var (
+	// montInvQ and montInvR are the inverses of the Montgomery constant 2^256
+	// modulo Q and R
+	montInvQ = montInverse(types.Q)
+	montInvR = montInverse(types.R)
+)
+
+// ParseZkey parses the snarkjs (0.3 and later) binary file representation of
+// the Groth16 proving key (.zkey) into the ProvingKey and the VerificationKey
+// structs. The H points of the zkey are the Lagrange basis used by the
+// snarkjs prover, which are converted into the HExps of the go-snark prover
+// with a FFT over G1, taking O(n·log(n)) scalar multiplications for the domain
+// size n, so for big circuits it is faster to convert the zkey once to the
+// go-snark binary format.
+func ParseZkey(f *os.File) (*types.Pk, *types.Vk, error) {
+	return ReadZkey(f)
+}
+
+// ReadZkey reads the snarkjs binary representation of the Groth16 proving key
+// into the ProvingKey and the VerificationKey structs, as ParseZkey
+func ReadZkey(rd io.Reader) (*types.Pk, *types.Vk, error) {
+	r := bufio.NewReader(rd)
+
+	// sections can be in any order, and all of them need the header to be
+	// parsed. Unknown sections (such as the contributions) are skipped.
+	sections, err := readSections(r, zkeyMagic, zkeyVersion, zkeySectionHeader,
+		zkeySectionGroth16, zkeySectionIC, zkeySectionCoefs, zkeySectionA, zkeySectionB1,
+		zkeySectionB2, zkeySectionC, zkeySectionH)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header, ok := sections[zkeySectionHeader]
+	if !ok {
+		return nil, nil, fmt.Errorf("zkey header section not found")
+	}
+	if len(header) != 4 { //nolint:gomnd
+		return nil, nil, fmt.Errorf("unexpected zkey header section size,"+
+			" expected: %v, actual: %v", 4, len(header))
+	}
+	if protocol := binary.LittleEndian.Uint32(header); protocol != zkeyProtocolGroth16 {
+		return nil, nil, fmt.Errorf("unsupported zkey protocol: %d", protocol)
+	}
+
+	g16, ok := sections[zkeySectionGroth16]
+	if !ok {
+		return nil, nil, fmt.Errorf("zkey groth16 header section not found")
+	}
+	pk, vk, err := parseZkeyGroth16Header(g16)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the points are parsed first, as their sections sizes bound the nVars
+	// and domainSize of the header used by the coefs
+	if vk.IC, err = zkeyG1Section(sections, zkeySectionIC, "IC", pk.NPublic+1); err != nil {
+		return nil, nil, err
+	}
+	if pk.A, err = zkeyG1Section(sections, zkeySectionA, "A", pk.NVars); err != nil {
+		return nil, nil, err
+	}
+	if pk.B1, err = zkeyG1Section(sections, zkeySectionB1, "B1", pk.NVars); err != nil {
+		return nil, nil, err
+	}
+	if pk.B2, err = zkeyG2Section(sections, zkeySectionB2, "B2", pk.NVars); err != nil {
+		return nil, nil, err
+	}
+	c, err := zkeyG1Section(sections, zkeySectionC, "C", pk.NVars-pk.NPublic-1)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the zkey does not contain the C points of the public signals
+	pk.C = make([]*bn256.G1, pk.NPublic+1, pk.NVars)
+	for i := range pk.C {
+		pk.C[i] = new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	}
+	pk.C = append(pk.C, c...)
+	h, err := zkeyG1Section(sections, zkeySectionH, "H", pk.DomainSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	pk.HExps = hExpsFromLagrange(h)
+
+	coefs, ok := sections[zkeySectionCoefs]
+	if !ok {
+		return nil, nil, fmt.Errorf("zkey coefs section not found")
+	}
+	pk.PolsA, pk.PolsB, err = parseZkeyCoefs(coefs, pk.NVars, pk.DomainSize)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pk, vk, nil
+}
+
+func parseZkeyGroth16Header(b []byte) (*types.Pk, *types.Vk, error) {
+	// n8q, q, n8r, r, nVars, nPublic, domainSize, alpha1, beta1, beta2,
+	// gamma2, delta1, delta2
+	size := 4 + zkeyN8 + 4 + zkeyN8 + 12 + 3*2*zkeyN8 + 3*4*zkeyN8 //nolint:gomnd
+	if len(b) != size {
+		return nil, nil, fmt.Errorf("unexpected zkey groth16 header section size,"+
+			" expected: %v, actual: %v", size, len(b))
+	}
+	n8q := int(binary.LittleEndian.Uint32(b[:4]))
+	q := new(big.Int).SetBytes(swapEndianness(b[4 : 4+zkeyN8]))
+	b = b[4+zkeyN8:]
+	n8r := int(binary.LittleEndian.Uint32(b[:4]))
+	r := new(big.Int).SetBytes(swapEndianness(b[4 : 4+zkeyN8]))
+	b = b[4+zkeyN8:]
+	if n8q != zkeyN8 || n8r != zkeyN8 || q.Cmp(types.Q) != 0 || r.Cmp(types.R) != 0 {
+		return nil, nil, fmt.Errorf("zkey curve is not bn256")
+	}
+
+	var pk types.Pk
+	var vk types.Vk
+	pk.NVars = int(binary.LittleEndian.Uint32(b[:4]))
+	pk.NPublic = int(binary.LittleEndian.Uint32(b[4:8]))
+	pk.DomainSize = int(binary.LittleEndian.Uint32(b[8:12]))
+	b = b[12:]
+	if pk.NPublic+1 > pk.NVars {
+		return nil, nil, fmt.Errorf("zkey has %d public signals and %d signals",
+			pk.NPublic, pk.NVars)
+	}
+	if pk.DomainSize == 0 || pk.DomainSize&(pk.DomainSize-1) != 0 ||
+		pk.DomainSize > 1<<zkeyMaxDomainBits {
+		return nil, nil, fmt.Errorf("invalid zkey domain size: %d", pk.DomainSize)
+	}
+
+	var err error
+	g1 := []**bn256.G1{&pk.VkAlpha1, &pk.VkBeta1}
+	for _, p := range g1 {
+		if *p, err = zkeyG1(b[:2*zkeyN8]); err != nil {
+			return nil, nil, err
+		}
+		b = b[2*zkeyN8:]
+	}
+	g2 := []**bn256.G2{&pk.VkBeta2, &vk.Gamma}
+	for _, p := range g2 {
+		if *p, err = zkeyG2(b[:4*zkeyN8]); err != nil {
+			return nil, nil, err
+		}
+		b = b[4*zkeyN8:]
+	}
+	if pk.VkDelta1, err = zkeyG1(b[:2*zkeyN8]); err != nil {
+		return nil, nil, err
+	}
+	if pk.VkDelta2, err = zkeyG2(b[2*zkeyN8:]); err != nil {
+		return nil, nil, err
+	}
+	vk.Alpha = pk.VkAlpha1
+	vk.Beta = pk.VkBeta2
+	vk.Delta = pk.VkDelta2
+	return &pk, &vk, nil
+}
+
+// parseZkeyCoefs parses the coefficients of the A and B matrices into the
+// PolsA and PolsB of the signals, where each value v is stored as v·R^2 (the
+// Montgomery form of v·R), being R = 2^256
+func parseZkeyCoefs(b []byte, nVars, domainSize int) ([]map[int]*big.Int,
+	[]map[int]*big.Int, error) {
+	if len(b) < 4 { //nolint:gomnd
+		return nil, nil, fmt.Errorf("zkey coefs section too short")
+	}
+	nCoefs := int(binary.LittleEndian.Uint32(b[:4]))
+	entrySize := zkeyCoefFixedFieldSize + zkeyN8
+	if len(b) != 4+nCoefs*entrySize {
+		return nil, nil, fmt.Errorf("unexpected zkey coefs section size,"+
+			" expected: %v, actual: %v", 4+nCoefs*entrySize, len(b))
+	}
+	pols := [2][]map[int]*big.Int{make([]map[int]*big.Int, nVars),
+		make([]map[int]*big.Int, nVars)}
+	for i := 0; i < nVars; i++ {
+		pols[0][i] = make(map[int]*big.Int)
+		pols[1][i] = make(map[int]*big.Int)
+	}
+	montInv2 := new(big.Int).Mul(montInvR, montInvR)
+	for i := 0; i < nCoefs; i++ {
+		e := b[4+i*entrySize : 4+(i+1)*entrySize]
+		matrix := int(binary.LittleEndian.Uint32(e[:4]))
+		constraint := int(binary.LittleEndian.Uint32(e[4:8]))
+		signal := int(binary.LittleEndian.Uint32(e[8:12]))
+		if matrix > 1 || constraint >= domainSize || signal >= nVars {
+			return nil, nil, fmt.Errorf("zkey coef %d out of range: matrix %d,"+
+				" constraint %d, signal %d", i, matrix, constraint, signal)
+		}
+		v := new(big.Int).SetBytes(swapEndianness(e[zkeyCoefFixedFieldSize:]))
+		v.Mul(v, montInv2)
+		if prev, ok := pols[matrix][signal][constraint]; ok {
+			// the coefficients are added, as the snarkjs prover does
+			v.Add(v, prev)
+		}
+		pols[matrix][signal][constraint] = v.Mod(v, types.R)
+	}
+	return pols[0], pols[1], nil
+}
+
+func zkeyG1Section(sections map[uint32][]byte, sType uint32, name string,
+	n int) ([]*bn256.G1, error) {
+	b, ok := sections[sType]
+	if !ok {
+		return nil, fmt.Errorf("zkey %s section not found", name)
+	}
+	if len(b) != n*2*zkeyN8 {
+		return nil, fmt.Errorf("unexpected zkey %s section size, expected: %v, actual: %v",
+			name, n*2*zkeyN8, len(b))
+	}
+	points := make([]*bn256.G1, n)
+	for i := range points {
+		p, err := zkeyG1(b[i*2*zkeyN8 : (i+1)*2*zkeyN8])
+		if err != nil {
+			return nil, fmt.Errorf("zkey %s point %d: %w", name, i, err)
+		}
+		points[i] = p
+	}
+	return points, nil
+}
+
+func zkeyG2Section(sections map[uint32][]byte, sType uint32, name string,
+	n int) ([]*bn256.G2, error) {
+	b, ok := sections[sType]
+	if !ok {
+		return nil, fmt.Errorf("zkey %s section not found", name)
+	}
+	if len(b) != n*4*zkeyN8 {
+		return nil, fmt.Errorf("unexpected zkey %s section size, expected: %v, actual: %v",
+			name, n*4*zkeyN8, len(b))
+	}
+	points := make([]*bn256.G2, n)
+	for i := range points {
+		p, err := zkeyG2(b[i*4*zkeyN8 : (i+1)*4*zkeyN8])
+		if err != nil {
+			return nil, fmt.Errorf("zkey %s point %d: %w", name, i, err)
+		}
+		points[i] = p
+	}
+	return points, nil
+}
+
+// zkeyG1 decodes the zkey G1 point (x, y), with the coordinates in
+// little-endian Montgomery form, where the point at infinity is (0, 0)
+func zkeyG1(b []byte) (*bn256.G1, error) {
+	m := append(fromMontLE(b[:zkeyN8]), fromMontLE(b[zkeyN8:2*zkeyN8])...)
+	p := new(bn256.G1)
+	if _, err := p.Unmarshal(m); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// zkeyG2 decodes the zkey G2 point (x0, x1, y0, y1), being x = x0 + x1·i,
+// with the coordinates in little-endian Montgomery form, into the bn256 G2
+// point, which is encoded as (x1, x0, y1, y0)
+func zkeyG2(b []byte) (*bn256.G2, error) {
+	var m []byte
+	for _, i := range []int{1, 0, 3, 2} {
+		m = append(m, fromMontLE(b[i*zkeyN8:(i+1)*zkeyN8])...)
+	}
+	p := new(bn256.G2)
+	if _, err := p.Unmarshal(m); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// montInverse returns the inverse of the Montgomery constant 2^256 modulo q
+func montInverse(q *big.Int) *big.Int {
+	return new(big.Int).ModInverse(new(big.Int).Lsh(big.NewInt(1), 256), q) //nolint:gomnd
+}
+
+// fromMontLE converts the little-endian Montgomery form of an element of the
+// base field into its big-endian 32 bytes representation
+func fromMontLE(b []byte) []byte {
+	v := new(big.Int).SetBytes(swapEndianness(b))
+	v.Mul(v, montInvQ)
+	return addPadding32(v.Mod(v, types.Q).Bytes())
+}
+
+// hExpsFromLagrange converts the zkey H points into the HExps of the prover.
+// The snarkjs prover evaluates A·B - C on the odd coset g·w^i of the domain
+// of size m, where g^m = -1, and the H points are the odd points of the
+// Lagrange basis of the domain of size 2m at t, H_i = L_(2i+1)(t)/delta. As
+// x^k·z(x) has degree < 2m, is zero on the domain and z(g·w^i) = -2,
+// HExps_k = t^k·z(t)/delta = -2·g^k·sum_i(w^(ik)·H_i), where the sum is the
+// FFT of the H points. As in the other formats there are m+1 HExps, being the
+// last one, which is not used by the prover as h has degree < m, the point at
+// infinity.
+func hExpsFromLagrange(h []*bn256.G1) []*bn256.G1 {
+	m := len(h)
+	logM := bits.TrailingZeros(uint(m))
+	hExps := make([]*bn256.G1, m, m+1)
+	for i := range h {
+		hExps[i] = new(bn256.G1).Set(h[i])
+	}
+	fftG1(hExps, rootOfUnity(logM))
+
+	g := rootOfUnity(logM + 1)
+	minusTwo := new(big.Int).Sub(types.R, big.NewInt(2)) //nolint:gomnd
+	parallel.For(m, func(k int) {
+		s := new(big.Int).Exp(g, big.NewInt(int64(k)), types.R)
+		s.Mul(s, minusTwo)
+		hExps[k].ScalarMult(hExps[k], s.Mod(s, types.R))
+	})
+	return append(hExps, new(bn256.G1).ScalarBaseMult(big.NewInt(0)))
+}
+
+// fftG1 computes in place the FFT of the points a over the powers of the
+// len(a)-th root of unity w, being the result k sum_i(w^(ik)·a_i)
+func fftG1(a []*bn256.G1, w *big.Int) {
+	n := len(a)
+	if n <= 1 {
+		return
+	}
+	logN := bits.TrailingZeros(uint(n))
+	for i := 0; i < n; i++ {
+		j := int(bits.Reverse(uint(i)) >> uint(bits.UintSize-logN))
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	wPowers := make([]*big.Int, n/2) //nolint:gomnd
+	wPowers[0] = big.NewInt(1)
+	for i := 1; i < len(wPowers); i++ {
+		wPowers[i] = new(big.Int).Mul(wPowers[i-1], w)
+		wPowers[i].Mod(wPowers[i], types.R)
+	}
+	for half := 1; half < n; half *= 2 {
+		step := n / (2 * half)          //nolint:gomnd
+		parallel.For(n/2, func(b int) { //nolint:gomnd
+			j := b % half
+			i := (b/half)*2*half + j //nolint:gomnd
+			t := new(bn256.G1).ScalarMult(a[i+half], wPowers[j*step])
+			a[i+half] = new(bn256.G1).Add(a[i], new(bn256.G1).Neg(t))
+			a[i] = new(bn256.G1).Add(a[i], t)
+		})
+	}
+}
+
+// rootOfUnity returns the primitive 2^bits root of unity, 5^((R-1)/2^bits),
+// which is the one used by snarkjs
+func rootOfUnity(bits int) *big.Int {
+	e := new(big.Int).Sub(types.R, big.NewInt(1))
+	e.Rsh(e, uint(bits))
+	return new(big.Int).Exp(big.NewInt(5), e, types.R) //nolint:gomnd
+}
@@ -0,0 +1,307 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/prover"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/types"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+func toMontLE(v, q *big.Int) []byte {
+	m := new(big.Int).Lsh(v, 256) //nolint:gomnd
+	return swapEndianness(addPadding32(m.Mod(m, q).Bytes()))
+}
+
+func zkeyG1Bytes(p *bn256.G1) []byte {
+	m := p.Marshal()
+	return append(toMontLE(new(big.Int).SetBytes(m[:32]), types.Q),
+		toMontLE(new(big.Int).SetBytes(m[32:]), types.Q)...)
+}
+
+func zkeyG2Bytes(p *bn256.G2) []byte {
+	m := p.Marshal()
+	var b []byte
+	for _, i := range []int{1, 0, 3, 2} {
+		b = append(b, toMontLE(new(big.Int).SetBytes(m[i*32:(i+1)*32]), types.Q)...)
+	}
+	return b
+}
+
+// zkeySections encodes the keys in the sections of the snarkjs .zkey format.
+// The H points are computed from the toxic waste as the odd points of the
+// Lagrange basis of the domain of size 2m at t divided by delta, which is how
+// snarkjs computes them from the powers of tau, and not from the HExps.
+func zkeySections(pk *types.Pk, vk *types.Vk, toxic *setup.Toxic) map[uint32][]byte {
+	var b [4]byte
+	u32 := func(v int) []byte {
+		binary.LittleEndian.PutUint32(b[:], uint32(v))
+		return append([]byte{}, b[:]...)
+	}
+	sections := make(map[uint32][]byte)
+	sections[zkeySectionHeader] = u32(zkeyProtocolGroth16)
+
+	var g16 []byte
+	g16 = append(g16, u32(32)...)
+	g16 = append(g16, swapEndianness(addPadding32(types.Q.Bytes()))...)
+	g16 = append(g16, u32(32)...)
+	g16 = append(g16, swapEndianness(addPadding32(types.R.Bytes()))...)
+	g16 = append(g16, u32(pk.NVars)...)
+	g16 = append(g16, u32(pk.NPublic)...)
+	g16 = append(g16, u32(pk.DomainSize)...)
+	g16 = append(g16, zkeyG1Bytes(pk.VkAlpha1)...)
+	g16 = append(g16, zkeyG1Bytes(pk.VkBeta1)...)
+	g16 = append(g16, zkeyG2Bytes(pk.VkBeta2)...)
+	g16 = append(g16, zkeyG2Bytes(vk.Gamma)...)
+	g16 = append(g16, zkeyG1Bytes(pk.VkDelta1)...)
+	g16 = append(g16, zkeyG2Bytes(pk.VkDelta2)...)
+	sections[zkeySectionGroth16] = g16
+
+	nCoefs := 0
+	var coefs []byte
+	// the coefficients are stored as v·R^2, being R = 2^256
+	r2 := new(big.Int).Lsh(big.NewInt(1), 512) //nolint:gomnd
+	for matrix, pols := range [][]map[int]*big.Int{pk.PolsA, pk.PolsB} {
+		for signal := range pols {
+			for _, constraint := range sortedKeys(pols[signal]) {
+				coefs = append(coefs, u32(matrix)...)
+				coefs = append(coefs, u32(constraint)...)
+				coefs = append(coefs, u32(signal)...)
+				v := new(big.Int).Mul(pols[signal][constraint], r2)
+				coefs = append(coefs, swapEndianness(addPadding32(v.Mod(v, types.R).Bytes()))...)
+				nCoefs++
+			}
+		}
+	}
+	sections[zkeySectionCoefs] = append(u32(nCoefs), coefs...)
+
+	g1Section := func(points []*bn256.G1) []byte {
+		var s []byte
+		for _, p := range points {
+			s = append(s, zkeyG1Bytes(p)...)
+		}
+		return s
+	}
+	sections[zkeySectionIC] = g1Section(vk.IC)
+	sections[zkeySectionA] = g1Section(pk.A)
+	sections[zkeySectionB1] = g1Section(pk.B1)
+	var b2 []byte
+	for _, p := range pk.B2 {
+		b2 = append(b2, zkeyG2Bytes(p)...)
+	}
+	sections[zkeySectionB2] = b2
+	sections[zkeySectionC] = g1Section(pk.C[pk.NPublic+1:])
+
+	// L_j(t) = w^j·(t^n - 1) / (n·(t - w^j)), for the domain of size n = 2m
+	n := big.NewInt(int64(2 * pk.DomainSize))
+	w := rootOfUnity(log2(2 * pk.DomainSize))
+	zt := new(big.Int).Exp(toxic.T, n, types.R)
+	zt.Sub(zt, big.NewInt(1))
+	deltaInv := new(big.Int).ModInverse(toxic.KDelta, types.R)
+	h := make([]*bn256.G1, pk.DomainSize)
+	for i := range h {
+		wj := new(big.Int).Exp(w, big.NewInt(int64(2*i+1)), types.R)
+		den := new(big.Int).Sub(toxic.T, wj)
+		den.Mul(den, n)
+		den.ModInverse(den.Mod(den, types.R), types.R)
+		l := new(big.Int).Mul(wj, zt)
+		l.Mul(l, den)
+		l.Mul(l, deltaInv)
+		h[i] = new(bn256.G1).ScalarBaseMult(l.Mod(l, types.R))
+	}
+	sections[zkeySectionH] = g1Section(h)
+	return sections
+}
+
+// log2 returns the logarithm of the power of two n
+func log2(n int) int {
+	b := 0
+	for 1<<b < n {
+		b++
+	}
+	return b
+}
+
+// zkeyToBin encodes the zkey sections, writing the H section first and an
+// unknown contributions section, to check that the sections order is not
+// assumed by the parser and that the unknown sections are skipped
+func zkeyToBin(sections map[uint32][]byte) []byte {
+	order := []uint32{zkeySectionH, zkeySectionHeader, zkeySectionGroth16, zkeySectionIC,
+		zkeySectionCoefs, zkeySectionA, zkeySectionB1, zkeySectionB2, zkeySectionC}
+	var b [4]byte
+	z := []byte(zkeyMagic)
+	binary.LittleEndian.PutUint32(b[:], zkeyVersion)
+	z = append(z, b[:]...)
+	nSections := 1
+	for _, s := range order {
+		if _, ok := sections[s]; ok {
+			nSections++
+		}
+	}
+	binary.LittleEndian.PutUint32(b[:], uint32(nSections))
+	z = append(z, b[:]...)
+	for _, s := range order {
+		if data, ok := sections[s]; ok {
+			z = append(z, binSection(s, data)...)
+		}
+	}
+	return append(z, binSection(10, []byte("contributions"))...) //nolint:gomnd
+}
+
+func parseZkeyBytes(t *testing.T, b []byte) (*types.Pk, *types.Vk, error) {
+	path := filepath.Join(t.TempDir(), "circuit.zkey")
+	require.Nil(t, ioutil.WriteFile(path, b, 0600))
+	f, err := os.Open(path) //nolint:gosec
+	require.Nil(t, err)
+	defer f.Close() //nolint:errcheck,gosec
+	return ParseZkey(f)
+}
+
+func assertPolsEqual(t *testing.T, expected, actual []map[int]*big.Int) {
+	require.Equal(t, len(expected), len(actual))
+	for i := range expected {
+		require.Equal(t, len(expected[i]), len(actual[i]), "signal %d", i)
+		for j, v := range expected[i] {
+			assert.Equal(t, v.String(), actual[i][j].String(), "signal %d constraint %d", i, j)
+		}
+	}
+}
+
+func TestRootOfUnity(t *testing.T) {
+	// the 2^28 root of unity of snarkjs (ffjavascript)
+	assert.Equal(t,
+		"19103219067921713944291392827692070036145651957329286315305642004821462161904",
+		rootOfUnity(28).String())
+	w := rootOfUnity(3)
+	assert.Equal(t, "1", new(big.Int).Exp(w, big.NewInt(8), types.R).String())
+	assert.NotEqual(t, "1", new(big.Int).Exp(w, big.NewInt(4), types.R).String())
+}
+
+func TestParseZkey(t *testing.T) {
+	toxic, err := setup.NewToxic()
+	require.Nil(t, err)
+	expectedPk, expectedVk, err := setup.GenerateTrustedSetupWithToxic(testutil.CubicR1CS(), toxic)
+	require.Nil(t, err)
+
+	pk, vk, err := parseZkeyBytes(t, zkeyToBin(zkeySections(expectedPk, expectedVk, toxic)))
+	require.Nil(t, err)
+	assert.Equal(t, expectedPk.NVars, pk.NVars)
+	assert.Equal(t, expectedPk.NPublic, pk.NPublic)
+	assert.Equal(t, expectedPk.DomainSize, pk.DomainSize)
+	assertPolsEqual(t, expectedPk.PolsA, pk.PolsA)
+	assertPolsEqual(t, expectedPk.PolsB, pk.PolsB)
+	for _, p := range [][2]*bn256.G1{{expectedPk.VkAlpha1, pk.VkAlpha1},
+		{expectedPk.VkBeta1, pk.VkBeta1}, {expectedPk.VkDelta1, pk.VkDelta1}} {
+		assert.Equal(t, p[0].Marshal(), p[1].Marshal())
+	}
+	for _, p := range [][2][]*bn256.G1{{expectedPk.A, pk.A}, {expectedPk.B1, pk.B1},
+		{expectedPk.C, pk.C}, {expectedPk.HExps[:pk.DomainSize], pk.HExps[:pk.DomainSize]},
+		{expectedVk.IC, vk.IC}} {
+		require.Equal(t, len(p[0]), len(p[1]))
+		for i := range p[0] {
+			assert.Equal(t, p[0][i].Marshal(), p[1][i].Marshal())
+		}
+	}
+	for i := range expectedPk.B2 {
+		assert.Equal(t, expectedPk.B2[i].Marshal(), pk.B2[i].Marshal())
+	}
+	assert.Equal(t, expectedVk.Alpha.Marshal(), vk.Alpha.Marshal())
+	assert.Equal(t, expectedVk.Beta.Marshal(), vk.Beta.Marshal())
+	assert.Equal(t, expectedVk.Gamma.Marshal(), vk.Gamma.Marshal())
+	assert.Equal(t, expectedVk.Delta.Marshal(), vk.Delta.Marshal())
+
+	proof, pubSignals, err := prover.GenerateProof(pk, testutil.CubicWitness(3))
+	require.Nil(t, err)
+	assert.Equal(t, "35", pubSignals[0].String())
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// conversion to the go-snark binary format
+	pkGoBin, err := PkToGoBin(pk)
+	require.Nil(t, err)
+	path := filepath.Join(t.TempDir(), "proving_key.go.bin")
+	require.Nil(t, ioutil.WriteFile(path, pkGoBin, 0600))
+	f, err := os.Open(path) //nolint:gosec
+	require.Nil(t, err)
+	defer f.Close() //nolint:errcheck,gosec
+	pk2, err := ParsePkGoBin(f)
+	require.Nil(t, err)
+	proof, pubSignals, err = prover.GenerateProof(pk2, testutil.CubicWitness(4))
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// reading from a stream
+	zkey := zkeyToBin(zkeySections(expectedPk, expectedVk, toxic))
+	pk3, vk3, err := ReadZkey(iotest.OneByteReader(bytes.NewReader(zkey)))
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk3, proof, pubSignals))
+	pk3GoBin, err := PkToGoBin(pk3)
+	require.Nil(t, err)
+	assert.Equal(t, pkGoBin, pk3GoBin)
+}
+
+func TestParseZkeyInvalid(t *testing.T) {
+	toxic, err := setup.NewToxic()
+	require.Nil(t, err)
+	pk, vk, err := setup.GenerateTrustedSetupWithToxic(testutil.CubicR1CS(), toxic)
+	require.Nil(t, err)
+	sections := func() map[uint32][]byte {
+		return zkeySections(pk, vk, toxic)
+	}
+	parse := func(b []byte) error {
+		_, _, err := parseZkeyBytes(t, b)
+		return err
+	}
+
+	b := zkeyToBin(sections())
+	require.Nil(t, parse(b))
+
+	// wrong magic
+	assert.NotNil(t, parse(append([]byte("zkez"), b[4:]...)))
+
+	// truncated file
+	assert.NotNil(t, parse(b[:len(b)-10]))
+
+	// missing section
+	s := sections()
+	delete(s, zkeySectionC)
+	assert.NotNil(t, parse(zkeyToBin(s)))
+
+	// not groth16
+	s = sections()
+	s[zkeySectionHeader] = []byte{2, 0, 0, 0}
+	assert.NotNil(t, parse(zkeyToBin(s)))
+
+	// not bn256
+	s = sections()
+	s[zkeySectionGroth16][4]++
+	assert.NotNil(t, parse(zkeyToBin(s)))
+
+	// coefficient of a signal out of range
+	s = sections()
+	binary.LittleEndian.PutUint32(s[zkeySectionCoefs][4+8:], uint32(pk.NVars))
+	assert.NotNil(t, parse(zkeyToBin(s)))
+
+	// point not on the curve
+	s = sections()
+	s[zkeySectionA][70]++
+	assert.NotNil(t, parse(zkeyToBin(s)))
+
+	// nVars not matching the points, with an empty coefs section, which must
+	// fail before allocating the polynomials
+	s = sections()
+	binary.LittleEndian.PutUint32(s[zkeySectionGroth16][4+32+4+32:], 0xffffffff)
+	s[zkeySectionCoefs] = []byte{0, 0, 0, 0}
+	assert.NotNil(t, parse(zkeyToBin(s)))
+}
@@ -25,6 +25,14 @@ func arrayOfZeroesE(n int) []*ff.Element {
 	return r[:]
 }
 
+func copyElements(a []*ff.Element) []*ff.Element {
+	r := make([]*ff.Element, len(a))
+	for i := range a {
+		r[i] = new(ff.Element).Set(a[i])
+	}
+	return r
+}
+
 func arrayOfZeroesG1(n int) []*bn256.G1 {
 	r := make([]*bn256.G1, n)
 	for i := 0; i < n; i++ {
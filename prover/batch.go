@@ -0,0 +1,129 @@
+package prover
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"runtime"
+	"sync"
+
+	"github.com/vocdoni/go-snark/types"
+)
+
+// BatchResult is the result of the proof generation of one of the witnesses
+// of a batch
+type BatchResult struct {
+	Proof      *types.Proof
+	PubSignals []*big.Int
+	Err        error
+}
+
+// GenerateProofs generates the proofs of the witnesses for the ProvingKey,
+// with up to concurrency proofs being generated at the same time (by default
+// runtime.NumCPU() when concurrency < 1). The results are returned in the
+// same order than the witnesses, each one with its own error. Unless
+// WithNumWorkers is given, the CPUs are shared between the concurrent proofs.
+// The progress of each proof is reported, with the index of its witness, to
+// the function of WithBatchProgress.
+func GenerateProofs(ctx context.Context, pk *types.Pk, ws []types.Witness, concurrency int,
+	opts ...Option) []BatchResult {
+	if err := checkPk(pk); err != nil {
+		return errorResults(len(ws), err)
+	}
+	return generateProofs(ctx, pk, &proverTables{}, ws, concurrency, opts)
+}
+
+// GenerateProofs generates the proofs of the witnesses using the precomputed
+// tables, with up to concurrency proofs being generated at the same time (by
+// default runtime.NumCPU() when concurrency < 1). The results are returned in
+// the same order than the witnesses, each one with its own error. Unless
+// WithNumWorkers is given, the CPUs are shared between the concurrent proofs.
+// The progress is reported as by the GenerateProofs function.
+func (p *Prover) GenerateProofs(ctx context.Context, ws []types.Witness, concurrency int,
+	opts ...Option) []BatchResult {
+	return generateProofs(ctx, p.pk, p.tables, ws, concurrency, opts)
+}
+
+func generateProofs(ctx context.Context, pk *types.Pk, t *proverTables, ws []types.Witness,
+	concurrency int, opts []Option) []BatchResult {
+	results := make([]BatchResult, len(ws))
+	numcpu := runtime.NumCPU()
+	if concurrency < 1 {
+		concurrency = numcpu
+	}
+	if concurrency > len(ws) {
+		concurrency = len(ws)
+	}
+	numWorkers := 1
+	if concurrency > 0 && numcpu/concurrency > 1 {
+		numWorkers = numcpu / concurrency
+	}
+	o, err := newOptions(append([]Option{WithNumWorkers(numWorkers)}, opts...))
+	if err != nil {
+		return errorResults(len(ws), err)
+	}
+	if o.progress != nil {
+		return errorResults(len(ws), fmt.Errorf("the progress of a batch of proofs is"+
+			" reported with WithBatchProgress, not WithProgress"))
+	}
+	// the random source and the progress function are shared by the
+	// concurrent proofs
+	o.rand = &lockedReader{r: o.rand}
+	var mu sync.Mutex
+	optionsOf := func(i int) *options {
+		if o.batch == nil {
+			return o
+		}
+		oi := *o
+		oi.progress = func(phase Phase, done, total int) {
+			mu.Lock()
+			defer mu.Unlock()
+			o.batch(i, phase, done, total)
+		}
+		return &oi
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			for i := range jobs {
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				proof, pubSignals, err := generateProof(ctx, pk, t, ws[i], optionsOf(i))
+				results[i] = BatchResult{Proof: proof, PubSignals: pubSignals, Err: err}
+			}
+			wg.Done()
+		}()
+	}
+	for i := range ws {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// errorResults returns n results with the error
+func errorResults(n int, err error) []BatchResult {
+	results := make([]BatchResult, n)
+	for i := range results {
+		results[i].Err = err
+	}
+	return results
+}
+
+type lockedReader struct {
+	mu sync.Mutex
+	r  io.Reader
+}
+
+func (l *lockedReader) Read(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.r.Read(p)
+}
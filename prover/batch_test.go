@@ -0,0 +1,87 @@
+package prover
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/types"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+func TestGenerateProofs(t *testing.T) {
+	r1cs := testutil.CubicR1CS()
+	pk, vk, err := setup.GenerateTrustedSetup(r1cs)
+	require.Nil(t, err)
+	p, err := NewProver(pk, 4)
+	require.Nil(t, err)
+
+	var ws []types.Witness
+	for x := int64(0); x < 10; x++ {
+		ws = append(ws, testutil.CubicWitness(x))
+	}
+	// invalid witness
+	ws[4][1] = big.NewInt(1)
+
+	for _, results := range [][]BatchResult{
+		GenerateProofs(context.Background(), pk, ws, 3, WithR1CS(r1cs)),
+		p.GenerateProofs(context.Background(), ws, 0, WithR1CS(r1cs)),
+	} {
+		require.Equal(t, len(ws), len(results))
+		for i, res := range results {
+			if i == 4 {
+				var cErr *ConstraintError
+				assert.True(t, errors.As(res.Err, &cErr))
+				continue
+			}
+			require.Nil(t, res.Err)
+			assert.Equal(t, ws[i][1], res.PubSignals[0])
+			assert.True(t, verifier.Verify(vk, res.Proof, res.PubSignals))
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, res := range p.GenerateProofs(ctx, ws, 2) {
+		assert.Equal(t, context.Canceled, res.Err)
+	}
+
+	for _, res := range GenerateProofs(context.Background(), pk, ws, 2, WithNumWorkers(0)) {
+		assert.NotNil(t, res.Err)
+	}
+	assert.Empty(t, GenerateProofs(context.Background(), pk, nil, 2))
+}
+
+func TestGenerateProofsProgress(t *testing.T) {
+	pk, _, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	ws := []types.Witness{testutil.CubicWitness(2), testutil.CubicWitness(3),
+		testutil.CubicWitness(4)}
+
+	// the last done of each witness and phase, which never decreases
+	last := make([]map[Phase]int, len(ws))
+	for i := range last {
+		last[i] = make(map[Phase]int)
+	}
+	results := GenerateProofs(context.Background(), pk, ws, 3,
+		WithBatchProgress(func(i int, phase Phase, done, total int) {
+			assert.GreaterOrEqual(t, done, last[i][phase])
+			assert.LessOrEqual(t, done, total)
+			last[i][phase] = done
+		}))
+	for i, res := range results {
+		require.Nil(t, res.Err)
+		assert.Equal(t, 3, len(last[i]))
+	}
+
+	// WithProgress can not tell the proofs of the batch apart
+	for _, res := range GenerateProofs(context.Background(), pk, ws, 3,
+		WithProgress(func(Phase, int, int) {})) {
+		assert.NotNil(t, res.Err)
+	}
+}
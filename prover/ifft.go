@@ -1,109 +1,176 @@
 package prover
 
 import (
-	"math"
 	"math/big"
+	"math/bits"
+	"sync"
 
 	"github.com/iden3/go-iden3-crypto/ff"
 	"github.com/vocdoni/go-snark/types"
 )
 
-type rootsT struct {
-	roots [][]*ff.Element
-	w     []*ff.Element
-}
-
-func newRootsT() rootsT {
-	var roots rootsT
+// maxDomainBits is the 2-adicity of the BN254 scalar field, so 2^28 is the
+// size of the largest FFT domain
+const maxDomainBits = 28
 
-	rem := new(big.Int).Sub(types.R, big.NewInt(1))
-	s := 0
-	for rem.Bit(0) == 0 { // rem.Bit==0 when even
-		s++
-		rem = new(big.Int).Rsh(rem, 1)
-	}
-	roots.w = make([]*ff.Element, s+1)
-	roots.w[s] = ff.NewElement().SetBigInt(fExp(big.NewInt(5), rem)) //nolint:gomnd
+// fftParallelSize is the minimum FFT size for which the butterflies of each
+// stage are distributed between goroutines
+const fftParallelSize = 1 << 10
 
-	n := s - 1
-	for n >= 0 {
-		roots.w[n] = ff.NewElement().Mul(roots.w[n+1], roots.w[n+1])
-		n--
-	}
-	roots.roots = make([][]*ff.Element, 50) // TODO WIP
+var (
+	rootsOnce sync.Once
+	// roots[k] is the primitive 2^k-th root of unity
+	roots [maxDomainBits + 1]ff.Element
 
-	roots.setRoots(15) //nolint:gomnd // TODO WIP
-	return roots
-}
+	rootPowersMu sync.Mutex
+	// rootPowers[k] contains the first 2^(k-1) powers of roots[k]
+	rootPowers [maxDomainBits + 1][]ff.Element
+)
 
-func (roots rootsT) setRoots(n int) {
-	for i := n; i >= 0 && nil == roots.roots[i]; i-- { // TODO tmp i<=len(r)
-		r := ff.NewElement().SetBigInt(big.NewInt(1))
-		nroots := 1 << i
-		var rootsi []*ff.Element
-		for j := 0; j < nroots; j++ {
-			rootsi = append(rootsi, r)
-			r = ff.NewElement().Mul(r, roots.w[i])
-		}
-		roots.roots[i] = rootsi
+// initRoots computes the primitive 2^28-th root of unity as 5^((R-1)/2^28)
+// and the smaller ones by squaring it
+func initRoots() {
+	rem := new(big.Int).Sub(types.R, big.NewInt(1))
+	rem.Rsh(rem, maxDomainBits)
+	roots[maxDomainBits].SetBigInt(fExp(big.NewInt(5), rem)) //nolint:gomnd
+	for k := maxDomainBits - 1; k >= 0; k-- {
+		roots[k].Square(&roots[k+1])
 	}
 }
 
-func fftroots(roots rootsT, pall []*ff.Element, bits, offset, step int) []*ff.Element {
-	n := 1 << bits
-	if n == 1 {
-		return []*ff.Element{pall[offset]}
-	} else if n == 2 { //nolint:gomnd
-		return []*ff.Element{
-			ff.NewElement().Add(pall[offset], pall[offset+step]), // TODO tmp
-			ff.NewElement().Sub(pall[offset], pall[offset+step]),
+// getRootPowers returns the powers of the 2^k-th root of unity used as
+// twiddle factors by the FFT of size 2^k, computing them only once
+func getRootPowers(k int) []ff.Element {
+	rootsOnce.Do(initRoots)
+	rootPowersMu.Lock()
+	defer rootPowersMu.Unlock()
+	if rootPowers[k] == nil {
+		p := make([]ff.Element, (1<<uint(k))>>1)
+		p[0].SetOne()
+		for j := 1; j < len(p); j++ {
+			p[j].Mul(&p[j-1], &roots[k])
 		}
+		rootPowers[k] = p
 	}
+	return rootPowers[k]
+}
 
-	ndiv2 := n >> 1
-	p1 := fftroots(roots, pall, bits-1, offset, step*2)      //nolint:gomnd
-	p2 := fftroots(roots, pall, bits-1, offset+step, step*2) //nolint:gomnd
+// cosetShift returns the generator of the odd coset of the domain of size n,
+// the primitive 2n-th root of unity
+func cosetShift(n int) *ff.Element {
+	rootsOnce.Do(initRoots)
+	return new(ff.Element).Set(&roots[bits.TrailingZeros(uint(n))+1])
+}
 
-	out := make([]*ff.Element, n)
-	for i := 0; i < ndiv2; i++ {
-		out[i] = ff.NewElement().Add(p1[i], ff.NewElement().Mul(roots.roots[bits][i], p2[i]))
-		out[i+ndiv2] = ff.NewElement().Sub(p1[i], ff.NewElement().Mul(roots.roots[bits][i], p2[i]))
+// fft evaluates in place the polynomial with coefficients a over the len(a)-th
+// roots of unity, where len(a) must be a power of two not greater than 2^28
+func fft(a []*ff.Element, numcpu int) {
+	n := len(a)
+	if n <= 1 {
+		return
+	}
+	logN := bits.TrailingZeros(uint(n))
+	w := getRootPowers(logN)
+	bitReverse(a)
+	if n < fftParallelSize {
+		numcpu = 1
+	}
+	for s := 1; s <= logN; s++ {
+		half := 1 << uint(s-1)
+		shift := uint(logN - s)
+		runRanges(n>>1, numcpu, func(start, end int) {
+			var t ff.Element
+			for b := start; b < end; b++ {
+				j := b & (half - 1)
+				i := (b>>uint(s-1))<<uint(s) + j
+				t.Mul(&w[j<<shift], a[i+half])
+				a[i+half].Sub(a[i], &t)
+				a[i].Add(a[i], &t)
+			}
+		})
 	}
-	return out
 }
 
-func fft(p []*ff.Element) []*ff.Element {
-	if len(p) <= 1 {
-		return p
+// ifft computes in place the coefficients of the polynomial with evaluations
+// a over the len(a)-th roots of unity
+func ifft(a []*ff.Element, numcpu int) {
+	n := len(a)
+	if n <= 1 {
+		return
+	}
+	fft(a, numcpu)
+	// evaluating at the inverse roots reverses all but the first element
+	for i, j := 1, n-1; i < j; i, j = i+1, j-1 {
+		a[i], a[j] = a[j], a[i]
+	}
+	nInv := new(ff.Element).Inverse(new(ff.Element).SetUint64(uint64(n)))
+	if n < fftParallelSize {
+		numcpu = 1
 	}
-	bits := math.Log2(float64(len(p)-1)) + 1
-	roots := newRootsT()
-	roots.setRoots(int(bits))
-	m := 1 << int(bits)
-	ep := extend(p, m)
-	res := fftroots(roots, ep, int(bits), 0, 1)
-	return res
+	runRanges(n, numcpu, func(start, end int) {
+		for i := start; i < end; i++ {
+			a[i].Mul(a[i], nInv)
+		}
+	})
 }
 
-func ifft(p []*ff.Element) []*ff.Element {
-	res := fft(p)
-	bits := math.Log2(float64(len(p)-1)) + 1
-	m := 1 << int(bits)
+// cosetFFT evaluates in place the polynomial with coefficients a over the odd
+// coset g·w^i of the len(a)-th roots of unity, where g^len(a) = -1
+func cosetFFT(a []*ff.Element, numcpu int) {
+	mulPowers(a, cosetShift(len(a)), numcpu)
+	fft(a, numcpu)
+}
 
-	twoinvm := ff.NewElement().SetBigInt(fInv(fMul(big.NewInt(1), big.NewInt(int64(m)))))
+// cosetIFFT computes in place the coefficients of the polynomial with
+// evaluations a over the odd coset of the len(a)-th roots of unity
+func cosetIFFT(a []*ff.Element, numcpu int) {
+	ifft(a, numcpu)
+	g := cosetShift(len(a))
+	mulPowers(a, new(ff.Element).Inverse(g), numcpu)
+}
 
-	var resn []*ff.Element
-	for i := 0; i < m; i++ {
-		resn = append(resn, ff.NewElement().Mul(res[(m-i)%m], twoinvm))
+// mulPowers multiplies each a[i] by g^i
+func mulPowers(a []*ff.Element, g *ff.Element, numcpu int) {
+	if len(a) < fftParallelSize {
+		numcpu = 1
 	}
+	runRanges(len(a), numcpu, func(start, end int) {
+		var gi ff.Element
+		gi.Exp(*g, uint64(start))
+		for i := start; i < end; i++ {
+			a[i].Mul(a[i], &gi)
+			gi.Mul(&gi, g)
+		}
+	})
+}
 
-	return resn
+// bitReverse permutes a, of power of two length, to the bit-reversed order of
+// its indexes
+func bitReverse(a []*ff.Element) {
+	shift := uint(bits.UintSize - bits.TrailingZeros(uint(len(a))))
+	for i := range a {
+		j := int(bits.Reverse(uint(i)) >> shift)
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
 }
 
-func extend(p []*ff.Element, e int) []*ff.Element {
-	if e == len(p) {
-		return p
+// runRanges splits [0, n) in numcpu ranges and calls f for each one of them
+// in its own goroutine
+func runRanges(n, numcpu int, f func(start, end int)) {
+	if numcpu <= 1 {
+		f(0, n)
+		return
+	}
+	var wg sync.WaitGroup
+	rs := ranges(n, numcpu)
+	wg.Add(len(rs))
+	for _, r := range rs {
+		go func(r [2]int) {
+			f(r[0], r[1])
+			wg.Done()
+		}(r)
 	}
-	z := arrayOfZeroesE(e - len(p))
-	return append(p, z...)
+	wg.Wait()
 }
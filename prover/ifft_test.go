@@ -0,0 +1,102 @@
+package prover
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iden3/go-iden3-crypto/ff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomElementArray(n int) []*ff.Element {
+	a := make([]*ff.Element, n)
+	for i := range a {
+		a[i] = ff.NewElement().SetRandom()
+	}
+	return a
+}
+
+// evalPolynomial evaluates the polynomial with coefficients a at x
+func evalPolynomial(a []*ff.Element, x *ff.Element) *ff.Element {
+	r := ff.NewElement()
+	for i := len(a) - 1; i >= 0; i-- {
+		r.Mul(r, x)
+		r.Add(r, a[i])
+	}
+	return r
+}
+
+func TestRoots(t *testing.T) {
+	rootsOnce.Do(initRoots)
+	one := ff.NewElement().SetOne()
+	assert.True(t, roots[0].Equal(one))
+	// roots[1] is -1
+	assert.False(t, roots[1].Equal(one))
+	assert.True(t, ff.NewElement().Square(&roots[1]).Equal(one))
+
+	w := getRootPowers(4)
+	assert.Equal(t, 8, len(w))
+	assert.True(t, ff.NewElement().Mul(&w[7], &roots[4]).Equal(
+		ff.NewElement().Neg(one)))
+}
+
+func TestFFT(t *testing.T) {
+	for _, n := range []int{1, 2, 4, 16, 256, 2048} {
+		for _, numcpu := range []int{1, 4} {
+			coeffs := randomElementArray(n)
+			a := copyElements(coeffs)
+			fft(a, numcpu)
+
+			rootsOnce.Do(initRoots)
+			x := ff.NewElement().SetOne()
+			wn := &roots[len(fmt.Sprintf("%b", n))-1]
+			for i := 0; i < n; i++ {
+				require.True(t, evalPolynomial(coeffs, x).Equal(a[i]),
+					fmt.Sprintf("n: %d, numcpu: %d, i: %d", n, numcpu, i))
+				x.Mul(x, wn)
+			}
+
+			ifft(a, numcpu)
+			for i := range a {
+				require.True(t, coeffs[i].Equal(a[i]))
+			}
+		}
+	}
+}
+
+func TestCosetFFT(t *testing.T) {
+	for _, n := range []int{1, 2, 8, 2048} {
+		coeffs := randomElementArray(n)
+		a := copyElements(coeffs)
+		cosetFFT(a, 4)
+
+		g := cosetShift(n)
+		// g^n = -1
+		gn := ff.NewElement().Exp(*g, uint64(n))
+		assert.True(t, gn.Equal(ff.NewElement().Neg(ff.NewElement().SetOne())))
+
+		x := new(ff.Element).Set(g)
+		wn := ff.NewElement().Square(g)
+		for i := 0; i < n; i++ {
+			require.True(t, evalPolynomial(coeffs, x).Equal(a[i]),
+				fmt.Sprintf("n: %d, i: %d", n, i))
+			x.Mul(x, wn)
+		}
+
+		cosetIFFT(a, 4)
+		for i := range a {
+			require.True(t, coeffs[i].Equal(a[i]))
+		}
+	}
+}
+
+func BenchmarkFFT(b *testing.B) {
+	n := 1 << 16
+	a := randomElementArray(n)
+	getRootPowers(16)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fft(a, 1)
+	}
+}
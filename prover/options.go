@@ -0,0 +1,127 @@
+package prover
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/vocdoni/go-snark/types"
+)
+
+const maxGroupSize = 16
+
+// MSMAlgorithm identifies the multi-scalar multiplication algorithm used to
+// generate the proof
+type MSMAlgorithm int
+
+const (
+	// MSMPippenger is the Pippenger bucket method, with the window size
+	// chosen from the number of points
+	MSMPippenger MSMAlgorithm = iota
+	// MSMStrauss is the Strauss-Shamir method without intermediate doubling,
+	// computing the tables of each group of points on every multiplication
+	MSMStrauss
+)
+
+// Option configures the proof generation
+type Option func(*options)
+
+type options struct {
+	numWorkers int
+	msm        MSMAlgorithm
+	gsize      int
+	rand       io.Reader
+	progress   ProgressFunc
+	batch      BatchProgressFunc
+	r1cs       *types.R1CS
+}
+
+func newOptions(opts []Option) (*options, error) {
+	o := &options{
+		numWorkers: runtime.NumCPU(),
+		msm:        MSMPippenger,
+		gsize:      gSize,
+		rand:       rand.Reader,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.numWorkers < 1 {
+		return nil, fmt.Errorf("number of workers must be at least 1, got %d", o.numWorkers)
+	}
+	if o.msm != MSMPippenger && o.msm != MSMStrauss {
+		return nil, fmt.Errorf("unknown multi-scalar multiplication algorithm: %d", o.msm)
+	}
+	if o.gsize < 1 || o.gsize > maxGroupSize {
+		return nil, fmt.Errorf("group size must be between 1 and %d, got %d",
+			maxGroupSize, o.gsize)
+	}
+	if o.rand == nil {
+		return nil, fmt.Errorf("random source can not be nil")
+	}
+	return o, nil
+}
+
+// WithNumWorkers sets the number of goroutines used to generate the proof,
+// by default runtime.NumCPU()
+func WithNumWorkers(n int) Option {
+	return func(o *options) {
+		o.numWorkers = n
+	}
+}
+
+// WithMSMAlgorithm sets the multi-scalar multiplication algorithm, by default
+// MSMPippenger
+func WithMSMAlgorithm(msm MSMAlgorithm) Option {
+	return func(o *options) {
+		o.msm = msm
+	}
+}
+
+// WithGroupSize sets the number of points grouped in each table of the
+// Strauss-Shamir multi-scalar multiplication (MSMStrauss), by default 6. The
+// table of each group has 2^gsize points, see prover/tables.md for the
+// trade-offs.
+func WithGroupSize(gsize int) Option {
+	return func(o *options) {
+		o.gsize = gsize
+	}
+}
+
+// WithRandReader sets the source of the r and s random values of the proof,
+// by default crypto/rand.Reader. A deterministic reader must only be used to
+// generate reproducible test vectors, as it breaks the zero-knowledge
+// property of the proof.
+func WithRandReader(r io.Reader) Option {
+	return func(o *options) {
+		o.rand = r
+	}
+}
+
+// WithProgress sets the function called to report the progress of each phase
+// of the proof generation. The batches of GenerateProofs use
+// WithBatchProgress instead, and fail with this option.
+func WithProgress(f ProgressFunc) Option {
+	return func(o *options) {
+		o.progress = f
+	}
+}
+
+// WithBatchProgress sets the function called to report the progress of each
+// phase of each proof of the batches of GenerateProofs, with the index of its
+// witness. It is ignored when generating a single proof.
+func WithBatchProgress(f BatchProgressFunc) Option {
+	return func(o *options) {
+		o.batch = f
+	}
+}
+
+// WithR1CS enables checking that the witness satisfies the constraints of the
+// R1CS before generating the proof, returning a *ConstraintError for the
+// first unsatisfied one
+func WithR1CS(r1cs *types.R1CS) Option {
+	return func(o *options) {
+		o.r1cs = r1cs
+	}
+}
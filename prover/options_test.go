@@ -0,0 +1,61 @@
+package prover
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+func TestGenerateProofWithOptions(t *testing.T) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+
+	for _, numWorkers := range []int{1, 3, 8} {
+		proof, pubSignals, err := GenerateProofWithOptions(context.Background(), pk,
+			testutil.CubicWitness(3), WithNumWorkers(numWorkers))
+		require.Nil(t, err)
+		assert.True(t, verifier.Verify(vk, proof, pubSignals))
+		for _, gsize := range []int{1, 4, 9} {
+			proof, pubSignals, err := GenerateProofWithOptions(context.Background(), pk,
+				testutil.CubicWitness(3), WithNumWorkers(numWorkers),
+				WithMSMAlgorithm(MSMStrauss), WithGroupSize(gsize))
+			require.Nil(t, err)
+			assert.True(t, verifier.Verify(vk, proof, pubSignals))
+		}
+	}
+
+	// the same random source generates the same proof
+	proof0, _, err := GenerateProofWithOptions(context.Background(), pk, testutil.CubicWitness(3),
+		WithRandReader(rand.New(rand.NewSource(1)))) //nolint:gosec
+	require.Nil(t, err)
+	proof1, _, err := GenerateProofWithOptions(context.Background(), pk, testutil.CubicWitness(3),
+		WithRandReader(rand.New(rand.NewSource(1))), WithNumWorkers(2)) //nolint:gosec
+	require.Nil(t, err)
+	assert.Equal(t, proof0.A.Marshal(), proof1.A.Marshal())
+	assert.Equal(t, proof0.B.Marshal(), proof1.B.Marshal())
+	assert.Equal(t, proof0.C.Marshal(), proof1.C.Marshal())
+
+	proof2, _, err := GenerateProofWithOptions(context.Background(), pk, testutil.CubicWitness(3),
+		WithRandReader(rand.New(rand.NewSource(2)))) //nolint:gosec
+	require.Nil(t, err)
+	assert.NotEqual(t, proof0.A.Marshal(), proof2.A.Marshal())
+
+	_, _, err = GenerateProofWithOptions(context.Background(), pk, testutil.CubicWitness(3),
+		WithNumWorkers(0))
+	assert.NotNil(t, err)
+	_, _, err = GenerateProofWithOptions(context.Background(), pk, testutil.CubicWitness(3),
+		WithMSMAlgorithm(MSMAlgorithm(2)))
+	assert.NotNil(t, err)
+	_, _, err = GenerateProofWithOptions(context.Background(), pk, testutil.CubicWitness(3),
+		WithGroupSize(0))
+	assert.NotNil(t, err)
+	_, _, err = GenerateProofWithOptions(context.Background(), pk, testutil.CubicWitness(3),
+		WithRandReader(nil))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,180 @@
+package prover
+
+import (
+	"context"
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+)
+
+const maxPippengerWindow = 16
+
+// scalar is a field element in little-endian 64 bit limbs
+type scalar [4]uint64
+
+func bigIntsToScalars(k []*big.Int) []scalar {
+	s := make([]scalar, len(k))
+	var b [32]byte
+	for i := range k {
+		ki := k[i]
+		if ki.Sign() < 0 || ki.Cmp(types.R) >= 0 {
+			ki = new(big.Int).Mod(ki, types.R)
+		}
+		ki.FillBytes(b[:])
+		for j := 0; j < 4; j++ {
+			s[i][j] = binary.BigEndian.Uint64(b[32-8*(j+1) : 32-8*j])
+		}
+	}
+	return s
+}
+
+// digit returns the c bits window of the scalar starting at the given bit
+func (s *scalar) digit(start, c int) int {
+	limb := start / 64      //nolint:gomnd
+	off := uint(start % 64) //nolint:gomnd
+	v := s[limb] >> off
+	if int(off)+c > 64 && limb+1 < len(s) {
+		v |= s[limb+1] << (64 - off)
+	}
+	return int(v & (1<<uint(c) - 1))
+}
+
+// pippengerWindowSize returns the window size in bits used by the Pippenger
+// multi-scalar multiplication for n points
+func pippengerWindowSize(n int) int {
+	c := bits.Len(uint(n)) - 3 //nolint:gomnd
+	if c < 3 {                 //nolint:gomnd
+		return 3
+	}
+	if c > maxPippengerWindow {
+		return maxPippengerWindow
+	}
+	return c
+}
+
+// Multiply scalars by G1 elements with the Pippenger bucket method. The
+// scalars are split in windows of c bits, and for each window the points are
+// accumulated in the bucket of its digit, so that the window sum can be
+// computed with 2*2^c additions using a running sum of the buckets. Stops
+// when the context is done, returning an incomplete result.
+func pippengerG1(ctx context.Context, a []*bn256.G1, k []*big.Int, qPrev *bn256.G1,
+	p *progressTracker) *bn256.G1 {
+	if len(a) == 0 {
+		return qPrev
+	}
+	c := pippengerWindowSize(len(a))
+	scalars := bigIntsToScalars(k[:len(a)])
+	nWindows := (types.R.BitLen() + c - 1) / c
+	buckets := make([]*bn256.G1, 1<<uint(c)-1)
+
+	R := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	reported := 0
+	for w := nWindows - 1; w >= 0; w-- {
+		if ctx.Err() != nil {
+			return qPrev
+		}
+		// shift R by the window, doubling it c times into new points, as the
+		// bn256 doubling can not be aliased
+		for i := 0; i < c; i++ {
+			R = new(bn256.G1).Add(R, R)
+		}
+
+		for j := range buckets {
+			buckets[j] = nil
+		}
+		for i := range a {
+			d := scalars[i].digit(w*c, c)
+			if d == 0 {
+				continue
+			}
+			if buckets[d-1] == nil {
+				buckets[d-1] = new(bn256.G1).Set(a[i])
+			} else {
+				buckets[d-1] = new(bn256.G1).Add(buckets[d-1], a[i])
+			}
+		}
+
+		// sum(d * bucket[d]) = sum of the running sums from the top bucket.
+		// Points are not added in place, as bn256 doubling can not be aliased
+		running := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		acc := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		for j := len(buckets) - 1; j >= 0; j-- {
+			if buckets[j] != nil {
+				running = new(bn256.G1).Add(running, buckets[j])
+			}
+			acc = new(bn256.G1).Add(acc, running)
+		}
+		R = new(bn256.G1).Add(R, acc)
+
+		done := len(a) * (nWindows - w) / nWindows
+		p.add(done - reported)
+		reported = done
+	}
+	if qPrev != nil {
+		return new(bn256.G1).Add(R, qPrev)
+	}
+	return R
+}
+
+// Multiply scalars by G2 elements with the Pippenger bucket method. Stops
+// when the context is done, returning an incomplete result.
+func pippengerG2(ctx context.Context, a []*bn256.G2, k []*big.Int, qPrev *bn256.G2,
+	p *progressTracker) *bn256.G2 {
+	if len(a) == 0 {
+		return qPrev
+	}
+	c := pippengerWindowSize(len(a))
+	scalars := bigIntsToScalars(k[:len(a)])
+	nWindows := (types.R.BitLen() + c - 1) / c
+	buckets := make([]*bn256.G2, 1<<uint(c)-1)
+
+	R := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+	reported := 0
+	for w := nWindows - 1; w >= 0; w-- {
+		if ctx.Err() != nil {
+			return qPrev
+		}
+		// shift R by the window, doubling it c times into new points, as the
+		// bn256 doubling can not be aliased
+		for i := 0; i < c; i++ {
+			R = new(bn256.G2).Add(R, R)
+		}
+
+		for j := range buckets {
+			buckets[j] = nil
+		}
+		for i := range a {
+			d := scalars[i].digit(w*c, c)
+			if d == 0 {
+				continue
+			}
+			if buckets[d-1] == nil {
+				buckets[d-1] = new(bn256.G2).Set(a[i])
+			} else {
+				buckets[d-1] = new(bn256.G2).Add(buckets[d-1], a[i])
+			}
+		}
+
+		// sum(d * bucket[d]) = sum of the running sums from the top bucket
+		running := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+		acc := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+		for j := len(buckets) - 1; j >= 0; j-- {
+			if buckets[j] != nil {
+				running = new(bn256.G2).Add(running, buckets[j])
+			}
+			acc = new(bn256.G2).Add(acc, running)
+		}
+		R = new(bn256.G2).Add(R, acc)
+
+		done := len(a) * (nWindows - w) / nWindows
+		p.add(done - reported)
+		reported = done
+	}
+	if qPrev != nil {
+		return new(bn256.G2).Add(R, qPrev)
+	}
+	return R
+}
@@ -0,0 +1,85 @@
+package prover
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/vocdoni/go-snark/types"
+)
+
+func TestPippengerG1(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 7, 64, 300} {
+		a := randomG1Array(n)
+		k := randomBigIntArray(n)
+		if n > 2 {
+			// zero scalar, repeated point and scalar above the field
+			k[0] = big.NewInt(0)
+			a[2] = a[1]
+			k[1] = new(big.Int).Add(k[1], types.R)
+		}
+		expected := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		for i := 0; i < n; i++ {
+			expected.Add(expected, new(bn256.G1).ScalarMult(a[i], k[i]))
+		}
+		p := newProgressTracker(nil, PhaseMSM, n)
+		q := pippengerG1(context.Background(), a, k,
+			new(bn256.G1).ScalarBaseMult(big.NewInt(0)), p)
+		assert.Equal(t, expected.Marshal(), q.Marshal(), fmt.Sprintf("n: %d", n))
+
+		q = pippengerG1(context.Background(), a, k, expected, p)
+		assert.Equal(t, new(bn256.G1).Add(expected, expected).Marshal(), q.Marshal())
+	}
+}
+
+func TestPippengerG2(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 7, 64} {
+		a := randomG2Array(n)
+		k := randomBigIntArray(n)
+		expected := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+		for i := 0; i < n; i++ {
+			expected.Add(expected, new(bn256.G2).ScalarMult(a[i], k[i]))
+		}
+		p := newProgressTracker(nil, PhaseMSM, n)
+		q := pippengerG2(context.Background(), a, k,
+			new(bn256.G2).ScalarBaseMult(big.NewInt(0)), p)
+		assert.Equal(t, expected.Marshal(), q.Marshal(), fmt.Sprintf("n: %d", n))
+	}
+}
+
+func TestPippengerVsStrauss(t *testing.T) {
+	n := n1
+	k := randomBigIntArray(n)
+	a := randomG1Array(n)
+	p := newProgressTracker(nil, PhaseMSM, n)
+
+	beforeT := time.Now()
+	q0 := scalarMultNoDoubleG1(a, k, nil, gSize)
+	fmt.Println("Strauss No Doubling G1 time elapsed:", time.Since(beforeT))
+	beforeT = time.Now()
+	q1 := pippengerG1(context.Background(), a, k, nil, p)
+	fmt.Println("Pippenger G1 time elapsed:", time.Since(beforeT))
+	assert.Equal(t, q0.Marshal(), q1.Marshal())
+}
+
+func BenchmarkMSM(b *testing.B) {
+	n := n1
+	k := randomBigIntArray(n)
+	a := randomG1Array(n)
+	p := newProgressTracker(nil, PhaseMSM, n)
+
+	b.Run("StraussNoDoubleG1", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			scalarMultNoDoubleG1(a, k, nil, gSize)
+		}
+	})
+	b.Run("PippengerG1", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pippengerG1(context.Background(), a, k, nil, p)
+		}
+	})
+}
@@ -0,0 +1,337 @@
+package prover
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/internal/parallel"
+	"github.com/vocdoni/go-snark/types"
+)
+
+const tablesMagic = "gsnt"
+
+const tablesVersion = 2
+
+// proverTables contains the Strauss-Shamir tables of the ProvingKey points,
+// where each table is computed from gsize consecutive points. The tables of C
+// start at the point NPublic+1, and the ones of HExps only cover the first
+// DomainSize points.
+type proverTables struct {
+	gsize int
+	a     []tableG1
+	b1    []tableG1
+	b2    []tableG2
+	c     []tableG1
+	hExps []tableG1
+}
+
+// Prover generates Groth16 zkSNARK proofs for a ProvingKey, using the
+// Strauss-Shamir tables of its points, which are computed only once. A Prover
+// can be used concurrently.
+type Prover struct {
+	pk     *types.Pk
+	tables *proverTables
+}
+
+// NewProver computes the tables of the ProvingKey points, grouping gsize
+// points in each table. See prover/tables.md for the size of the tables.
+func NewProver(pk *types.Pk, gsize int) (*Prover, error) {
+	if gsize < 1 || gsize > maxGroupSize {
+		return nil, fmt.Errorf("group size must be between 1 and %d, got %d",
+			maxGroupSize, gsize)
+	}
+	if err := checkPk(pk); err != nil {
+		return nil, err
+	}
+	t := &proverTables{gsize: gsize}
+	t.a = newTablesG1(pk.A, gsize)
+	t.b1 = newTablesG1(pk.B1, gsize)
+	t.b2 = newTablesG2(pk.B2, gsize)
+	t.c = newTablesG1(pk.C[pk.NPublic+1:], gsize)
+	t.hExps = newTablesG1(pk.HExps[:pk.DomainSize], gsize)
+	return &Prover{pk: pk, tables: t}, nil
+}
+
+// GenerateProof generates the Groth16 zkSNARK proof of the witness, aborting
+// the generation and returning the context error when the context is done.
+// The options WithMSMAlgorithm and WithGroupSize have no effect, as the
+// precomputed tables are used.
+func (p *Prover) GenerateProof(ctx context.Context, w types.Witness,
+	opts ...Option) (*types.Proof, []*big.Int, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return generateProof(ctx, p.pk, p.tables, w, o)
+}
+
+// ProvingKey returns the ProvingKey of the Prover
+func (p *Prover) ProvingKey() *types.Pk {
+	return p.pk
+}
+
+func newTablesG1(a []*bn256.G1, gsize int) []tableG1 {
+	t := make([]tableG1, (len(a)+gsize-1)/gsize)
+	parallel.For(len(t), func(i int) {
+		end := (i + 1) * gsize
+		if end > len(a) {
+			end = len(a)
+		}
+		t[i].newTableG1(a[i*gsize:end], gsize, false)
+	})
+	return t
+}
+
+func newTablesG2(a []*bn256.G2, gsize int) []tableG2 {
+	t := make([]tableG2, (len(a)+gsize-1)/gsize)
+	parallel.For(len(t), func(i int) {
+		end := (i + 1) * gsize
+		if end > len(a) {
+			end = len(a)
+		}
+		t[i].newTableG2(a[i*gsize:end], gsize, false)
+	})
+	return t
+}
+
+// mulTablesG1 multiplies the scalars by the precomputed tables, in blocks of
+// msmBlockSize points, stopping when the context is done
+func mulTablesG1(ctx context.Context, t []tableG1, k []*big.Int, qPrev *bn256.G1, gsize int,
+	p *progressTracker) *bn256.G1 {
+	blockSize := (msmBlockSize + gsize - 1) / gsize
+	for i := 0; i < len(t); i += blockSize {
+		if ctx.Err() != nil {
+			return qPrev
+		}
+		j := i + blockSize
+		if j > len(t) {
+			j = len(t)
+		}
+		kEnd := j * gsize
+		if kEnd > len(k) {
+			kEnd = len(k)
+		}
+		qPrev = mulTableNoDoubleG1(t[i:j], k[i*gsize:kEnd], qPrev, gsize)
+		p.add(kEnd - i*gsize)
+	}
+	return qPrev
+}
+
+// mulTablesG2 multiplies the scalars by the precomputed tables, in blocks of
+// msmBlockSize points, stopping when the context is done
+func mulTablesG2(ctx context.Context, t []tableG2, k []*big.Int, qPrev *bn256.G2, gsize int,
+	p *progressTracker) *bn256.G2 {
+	blockSize := (msmBlockSize + gsize - 1) / gsize
+	for i := 0; i < len(t); i += blockSize {
+		if ctx.Err() != nil {
+			return qPrev
+		}
+		j := i + blockSize
+		if j > len(t) {
+			j = len(t)
+		}
+		kEnd := j * gsize
+		if kEnd > len(k) {
+			kEnd = len(k)
+		}
+		qPrev = mulTableNoDoubleG2(t[i:j], k[i*gsize:kEnd], qPrev, gsize)
+		p.add(kEnd - i*gsize)
+	}
+	return qPrev
+}
+
+// WriteTables writes the precomputed tables to w, so they can be loaded with
+// NewProverFromTables instead of computing them again. The format is the
+// magic "gsnt", followed by the uint32 little-endian version, gsize, nVars,
+// nPublic and domainSize, the points of the A, B1, B2, C and HExps tables,
+// and the sha256 hash of the ProvingKey points followed by all the previous
+// bytes, which ties the tables to the ProvingKey and detects their
+// corruption.
+func (p *Prover) WriteTables(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	h := sha256.New()
+	h.Write(pkPointsHash(p.pk)) //nolint:errcheck,gosec
+	bw := io.MultiWriter(buf, h)
+	if _, err := io.WriteString(bw, tablesMagic); err != nil {
+		return err
+	}
+	var b [4]byte
+	for _, v := range []int{tablesVersion, p.tables.gsize, p.pk.NVars, p.pk.NPublic,
+		p.pk.DomainSize} {
+		binary.LittleEndian.PutUint32(b[:], uint32(v))
+		if _, err := bw.Write(b[:]); err != nil {
+			return err
+		}
+	}
+	for _, tables := range [][]tableG1{p.tables.a, p.tables.b1} {
+		for _, t := range tables {
+			if _, err := bw.Write(t.Marshal()); err != nil {
+				return err
+			}
+		}
+	}
+	for _, t := range p.tables.b2 {
+		if _, err := bw.Write(t.Marshal()); err != nil {
+			return err
+		}
+	}
+	for _, tables := range [][]tableG1{p.tables.c, p.tables.hExps} {
+		for _, t := range tables {
+			if _, err := bw.Write(t.Marshal()); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := buf.Write(h.Sum(nil)); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// pkPointsHash returns the sha256 hash of the ProvingKey points used by the
+// tables
+func pkPointsHash(pk *types.Pk) []byte {
+	h := sha256.New()
+	for _, points := range [][]*bn256.G1{pk.A, pk.B1} {
+		for _, p := range points {
+			h.Write(p.Marshal()) //nolint:errcheck,gosec
+		}
+	}
+	for _, p := range pk.B2 {
+		h.Write(p.Marshal()) //nolint:errcheck,gosec
+	}
+	for _, points := range [][]*bn256.G1{pk.C[pk.NPublic+1:], pk.HExps[:pk.DomainSize]} {
+		for _, p := range points {
+			h.Write(p.Marshal()) //nolint:errcheck,gosec
+		}
+	}
+	return h.Sum(nil)
+}
+
+// NewProverFromTables creates a Prover for the ProvingKey loading the tables
+// written by WriteTables, checking that they were computed from the same
+// ProvingKey and that they are not corrupted
+func NewProverFromTables(pk *types.Pk, r io.Reader) (*Prover, error) {
+	buf := bufio.NewReader(r)
+	b := make([]byte, 24) //nolint:gomnd
+	if _, err := io.ReadFull(buf, b); err != nil {
+		return nil, err
+	}
+	if string(b[:4]) != tablesMagic {
+		return nil, fmt.Errorf("invalid tables magic: %q", b[:4])
+	}
+	if v := binary.LittleEndian.Uint32(b[4:8]); v != tablesVersion {
+		return nil, fmt.Errorf("unsupported tables version: %d", v)
+	}
+	gsize := int(binary.LittleEndian.Uint32(b[8:12]))
+	if gsize < 1 || gsize > maxGroupSize {
+		return nil, fmt.Errorf("invalid tables group size: %d", gsize)
+	}
+	nVars := int(binary.LittleEndian.Uint32(b[12:16]))
+	nPublic := int(binary.LittleEndian.Uint32(b[16:20]))
+	domainSize := int(binary.LittleEndian.Uint32(b[20:24]))
+	if nVars != pk.NVars || nPublic != pk.NPublic || domainSize != pk.DomainSize {
+		return nil, fmt.Errorf("tables do not match the proving key, tables"+
+			" (nVars: %d, nPublic: %d, domainSize: %d), proving key (nVars: %d,"+
+			" nPublic: %d, domainSize: %d)", nVars, nPublic, domainSize,
+			pk.NVars, pk.NPublic, pk.DomainSize)
+	}
+	if err := checkPk(pk); err != nil {
+		return nil, err
+	}
+	// the tables are hashed as they are read, after the ProvingKey points and
+	// the header
+	h := sha256.New()
+	h.Write(pkPointsHash(pk)) //nolint:errcheck,gosec
+	h.Write(b)                //nolint:errcheck,gosec
+	br := io.TeeReader(buf, h)
+
+	t := &proverTables{gsize: gsize}
+	var err error
+	if t.a, err = readTablesG1(br, pk.A, gsize); err != nil {
+		return nil, err
+	}
+	if t.b1, err = readTablesG1(br, pk.B1, gsize); err != nil {
+		return nil, err
+	}
+	if t.b2, err = readTablesG2(br, pk.B2, gsize); err != nil {
+		return nil, err
+	}
+	if t.c, err = readTablesG1(br, pk.C[pk.NPublic+1:], gsize); err != nil {
+		return nil, err
+	}
+	if t.hExps, err = readTablesG1(br, pk.HExps[:pk.DomainSize], gsize); err != nil {
+		return nil, err
+	}
+	sum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(buf, sum); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(sum, h.Sum(nil)) {
+		return nil, fmt.Errorf("tables hash does not match, the tables are corrupted" +
+			" or do not belong to the proving key")
+	}
+	return &Prover{pk: pk, tables: t}, nil
+}
+
+// readTablesG1 reads the tables of the points, checking that the table
+// entries of a single point match the given points
+func readTablesG1(r io.Reader, a []*bn256.G1, gsize int) ([]tableG1, error) {
+	t := make([]tableG1, (len(a)+gsize-1)/gsize)
+	b := make([]byte, 64) //nolint:gomnd
+	for i := range t {
+		t[i].data = make([]*bn256.G1, 1<<uint(gsize))
+		for j := range t[i].data {
+			if _, err := io.ReadFull(r, b); err != nil {
+				return nil, err
+			}
+			t[i].data[j] = new(bn256.G1)
+			if _, err := t[i].data[j].Unmarshal(b); err != nil {
+				return nil, err
+			}
+			// power of 2 entries are the points of the group
+			if j&(j-1) == 0 && j != 0 {
+				n := i*gsize + bits.TrailingZeros(uint(j))
+				if n < len(a) && !bytes.Equal(b, a[n].Marshal()) {
+					return nil, fmt.Errorf("tables do not match the proving key point %d", n)
+				}
+			}
+		}
+	}
+	return t, nil
+}
+
+// readTablesG2 reads the tables of the points, checking that the table
+// entries of a single point match the given points
+func readTablesG2(r io.Reader, a []*bn256.G2, gsize int) ([]tableG2, error) {
+	t := make([]tableG2, (len(a)+gsize-1)/gsize)
+	b := make([]byte, 128) //nolint:gomnd
+	for i := range t {
+		t[i].data = make([]*bn256.G2, 1<<uint(gsize))
+		for j := range t[i].data {
+			if _, err := io.ReadFull(r, b); err != nil {
+				return nil, err
+			}
+			t[i].data[j] = new(bn256.G2)
+			if _, err := t[i].data[j].Unmarshal(b); err != nil {
+				return nil, err
+			}
+			// power of 2 entries are the points of the group
+			if j&(j-1) == 0 && j != 0 {
+				n := i*gsize + bits.TrailingZeros(uint(j))
+				if n < len(a) && !bytes.Equal(b, a[n].Marshal()) {
+					return nil, fmt.Errorf("tables do not match the proving key point %d", n)
+				}
+			}
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,73 @@
+package prover
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+func TestProver(t *testing.T) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+
+	for _, gsize := range []int{1, 4, 6} {
+		p, err := NewProver(pk, gsize)
+		require.Nil(t, err)
+		for _, x := range []int64{2, 3, 17} {
+			for _, numWorkers := range []int{1, 3} {
+				proof, pubSignals, err := p.GenerateProof(context.Background(),
+					testutil.CubicWitness(x), WithNumWorkers(numWorkers))
+				require.Nil(t, err)
+				assert.True(t, verifier.Verify(vk, proof, pubSignals))
+			}
+		}
+	}
+
+	_, err = NewProver(pk, 0)
+	assert.NotNil(t, err)
+}
+
+func TestProverTables(t *testing.T) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	p, err := NewProver(pk, 3)
+	require.Nil(t, err)
+
+	var buf bytes.Buffer
+	err = p.WriteTables(&buf)
+	require.Nil(t, err)
+	tables := buf.Bytes()
+
+	p2, err := NewProverFromTables(pk, bytes.NewReader(tables))
+	require.Nil(t, err)
+	proof, pubSignals, err := p2.GenerateProof(context.Background(), testutil.CubicWitness(5))
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// truncated tables
+	_, err = NewProverFromTables(pk, bytes.NewReader(tables[:len(tables)-1]))
+	assert.NotNil(t, err)
+
+	// table entry not of a single point replaced by another valid point
+	bad := append([]byte{}, tables...)
+	copy(bad[24+3*64:24+4*64], tables[24+1*64:24+2*64])
+	_, err = NewProverFromTables(pk, bytes.NewReader(bad))
+	assert.NotNil(t, err)
+
+	// tables of another proving key
+	pk2, _, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	_, err = NewProverFromTables(pk2, bytes.NewReader(tables))
+	assert.NotNil(t, err)
+
+	// invalid magic
+	tables[0] = 'x'
+	_, err = NewProverFromTables(pk, bytes.NewReader(tables))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,63 @@
+package prover
+
+import "sync"
+
+// Phase identifies each one of the phases of the proof generation
+type Phase int
+
+const (
+	// PhaseMSM is the multi-scalar multiplication of the witness by the
+	// A, B1, B2 and C points of the ProvingKey
+	PhaseMSM Phase = iota
+	// PhaseH is the computation of the H polynomial
+	PhaseH
+	// PhaseHMSM is the multi-scalar multiplication of the H polynomial by the
+	// HExps points of the ProvingKey
+	PhaseHMSM
+)
+
+// String implements the Stringer interface for Phase
+func (p Phase) String() string {
+	switch p {
+	case PhaseMSM:
+		return "A/B/C MSM"
+	case PhaseH:
+		return "H polynomial"
+	case PhaseHMSM:
+		return "H MSM"
+	}
+	return "unknown"
+}
+
+// ProgressFunc is called during the proof generation each time that a unit
+// of work of the phase is completed, where done out of total units are
+// already completed. It is never called concurrently.
+type ProgressFunc func(phase Phase, done, total int)
+
+// BatchProgressFunc is called during the generation of a batch of proofs as
+// ProgressFunc, for the proof of the witness at index i of the batch. The
+// concurrent proofs report their own progress, so done never decreases
+// between the calls of the same i and phase. It is never called concurrently.
+type BatchProgressFunc func(i int, phase Phase, done, total int)
+
+type progressTracker struct {
+	mu    sync.Mutex
+	f     ProgressFunc
+	phase Phase
+	done  int
+	total int
+}
+
+func newProgressTracker(f ProgressFunc, phase Phase, total int) *progressTracker {
+	return &progressTracker{f: f, phase: phase, total: total}
+}
+
+func (p *progressTracker) add(n int) {
+	if p.f == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += n
+	p.f(p.phase, p.done, p.total)
+}
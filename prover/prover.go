@@ -1,13 +1,13 @@
 package prover
 
 import (
-	"crypto/rand"
-	"math"
+	"context"
+	"io"
 	"math/big"
-	"runtime"
 	"sync"
 
 	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-iden3-crypto/ff"
 	"github.com/iden3/go-iden3-crypto/utils"
 	"github.com/vocdoni/go-snark/types"
 )
@@ -17,10 +17,18 @@ const (
 	gSize = 6
 )
 
-func randBigInt() (*big.Int, error) {
+const (
+	// msmBlockSize is the number of points multiplied by the Strauss-Shamir
+	// method between the checks of the context cancellation
+	msmBlockSize = 1 << 10
+	// hSteps is the number of steps reported in the PhaseH progress
+	hSteps = 4
+)
+
+func randBigInt(rnd io.Reader) (*big.Int, error) {
 	maxbits := types.R.BitLen()
 	b := make([]byte, (maxbits/8)-1)
-	_, err := rand.Read(b)
+	_, err := io.ReadFull(rnd, b)
 	if err != nil {
 		return nil, err
 	}
@@ -32,56 +40,95 @@ func randBigInt() (*big.Int, error) {
 
 // GenerateProof generates the Groth16 zkSNARK proof
 func GenerateProof(pk *types.Pk, w types.Witness) (*types.Proof, []*big.Int, error) {
+	return GenerateProofWithOptions(context.Background(), pk, w)
+}
+
+// GenerateProofWithContext generates the Groth16 zkSNARK proof, aborting the
+// generation and returning the context error when the context is done. If
+// progress is not nil, it is called to report the progress of each phase.
+func GenerateProofWithContext(ctx context.Context, pk *types.Pk, w types.Witness,
+	progress ProgressFunc) (*types.Proof, []*big.Int, error) {
+	return GenerateProofWithOptions(ctx, pk, w, WithProgress(progress))
+}
+
+// GenerateCheckedProof generates the Groth16 zkSNARK proof after checking
+// that the witness satisfies all the constraints of the R1CS, returning a
+// *ConstraintError for the first unsatisfied one instead of an invalid proof
+func GenerateCheckedProof(pk *types.Pk, r1cs *types.R1CS, w types.Witness) (*types.Proof,
+	[]*big.Int, error) {
+	return GenerateProofWithOptions(context.Background(), pk, w, WithR1CS(r1cs))
+}
+
+// GenerateProofWithOptions generates the Groth16 zkSNARK proof with the given
+// options, aborting the generation and returning the context error when the
+// context is done. The ProvingKey and the witness are checked first, returning
+// an error matching ErrInconsistentKey, ErrWitnessLength, ErrWitnessOne or
+// ErrOutOfField (as a *FieldError) when they are not valid.
+func GenerateProofWithOptions(ctx context.Context, pk *types.Pk, w types.Witness,
+	opts ...Option) (*types.Proof, []*big.Int, error) {
+	o, err := newOptions(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkPk(pk); err != nil {
+		return nil, nil, err
+	}
+	return generateProof(ctx, pk, &proverTables{}, w, o)
+}
+
+// generateProof generates the Groth16 zkSNARK proof, using the precomputed
+// tables of the ProvingKey points when available. The ProvingKey must have
+// been checked by checkPk, on each call or once by NewProver.
+func generateProof(ctx context.Context, pk *types.Pk, t *proverTables, w types.Witness,
+	o *options) (*types.Proof, []*big.Int, error) {
+	if err := checkWitness(pk, w); err != nil {
+		return nil, nil, err
+	}
+	if o.r1cs != nil {
+		if err := CheckWitness(o.r1cs, w); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	var proof types.Proof
 
-	r, err := randBigInt()
+	r, err := randBigInt(o.rand)
 	if err != nil {
 		return nil, nil, err
 	}
-	s, err := randBigInt()
+	s, err := randBigInt(o.rand)
 	if err != nil {
 		return nil, nil, err
 	}
+	progress := o.progress
 
 	// BEGIN PAR
-	numcpu := runtime.NumCPU()
+	numcpu := o.numWorkers
 
 	proofA := arrayOfZeroesG1(numcpu)
 	proofB := arrayOfZeroesG2(numcpu)
 	proofC := arrayOfZeroesG1(numcpu)
 	proofBG1 := arrayOfZeroesG1(numcpu)
-	gsize := gSize
+	pMSM := newProgressTracker(progress, PhaseMSM, 4*pk.NVars-pk.NPublic-1) //nolint:gomnd
+	nC := pk.NVars - pk.NPublic - 1
+	rangesC := alignedRanges(nC, numcpu, t.gsize)
 	var wg1 sync.WaitGroup
 	wg1.Add(numcpu)
-	for _cpu, _ranges := range ranges(pk.NVars, numcpu) {
+	for _cpu, _ranges := range alignedRanges(pk.NVars, numcpu, t.gsize) {
 		// split 1
-		go func(cpu int, ranges [2]int) {
-			proofA[cpu] = scalarMultNoDoubleG1(pk.A[ranges[0]:ranges[1]],
-				w[ranges[0]:ranges[1]],
-				proofA[cpu],
-				gsize)
-			proofB[cpu] = scalarMultNoDoubleG2(pk.B2[ranges[0]:ranges[1]],
-				w[ranges[0]:ranges[1]],
-				proofB[cpu],
-				gsize)
-			proofBG1[cpu] = scalarMultNoDoubleG1(pk.B1[ranges[0]:ranges[1]],
-				w[ranges[0]:ranges[1]],
-				proofBG1[cpu],
-				gsize)
-			minLim := pk.NPublic + 1
-			if ranges[0] > pk.NPublic+1 {
-				minLim = ranges[0]
-			}
-			if ranges[1] > pk.NPublic+1 {
-				proofC[cpu] = scalarMultNoDoubleG1(pk.C[minLim:ranges[1]],
-					w[minLim:ranges[1]],
-					proofC[cpu],
-					gsize)
-			}
+		go func(cpu int, ranges, rangesC [2]int) {
+			proofA[cpu] = msmG1(ctx, pk.A, t.a, w, ranges, proofA[cpu], t.gsize, o, pMSM)
+			proofB[cpu] = msmG2(ctx, pk.B2, t.b2, w, ranges, proofB[cpu], t.gsize, o, pMSM)
+			proofBG1[cpu] = msmG1(ctx, pk.B1, t.b1, w, ranges, proofBG1[cpu], t.gsize, o, pMSM)
+			proofC[cpu] = msmG1(ctx, pk.C[pk.NPublic+1:], t.c, w[pk.NPublic+1:], rangesC,
+				proofC[cpu], t.gsize, o, pMSM)
 			wg1.Done()
-		}(_cpu, _ranges)
+		}(_cpu, _ranges, rangesC[_cpu])
 	}
 	wg1.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 	// join 1
 	for cpu := 1; cpu < numcpu; cpu++ {
 		proofA[0].Add(proofA[0], proofA[cpu])
@@ -94,7 +141,10 @@ func GenerateProof(pk *types.Pk, w types.Witness) (*types.Proof, []*big.Int, err
 	proof.C = proofC[0]
 	// END PAR
 
-	h := calculateH(pk, w)
+	h, err := calculateH(ctx, pk, w, numcpu, newProgressTracker(progress, PhaseH, hSteps))
+	if err != nil {
+		return nil, nil, err
+	}
 
 	proof.A.Add(proof.A, pk.VkAlpha1)
 	proof.A.Add(proof.A, new(bn256.G1).ScalarMult(pk.VkDelta1, r))
@@ -106,19 +156,21 @@ func GenerateProof(pk *types.Pk, w types.Witness) (*types.Proof, []*big.Int, err
 	proofBG1[0].Add(proofBG1[0], new(bn256.G1).ScalarMult(pk.VkDelta1, s))
 
 	proofC = arrayOfZeroesG1(numcpu)
+	pHMSM := newProgressTracker(progress, PhaseHMSM, len(h))
 	var wg2 sync.WaitGroup
 	wg2.Add(numcpu)
-	for _cpu, _ranges := range ranges(len(h), numcpu) {
+	for _cpu, _ranges := range alignedRanges(len(h), numcpu, t.gsize) {
 		// split 2
 		go func(cpu int, ranges [2]int) {
-			proofC[cpu] = scalarMultNoDoubleG1(pk.HExps[ranges[0]:ranges[1]],
-				h[ranges[0]:ranges[1]],
-				proofC[cpu],
-				gsize)
+			proofC[cpu] = msmG1(ctx, pk.HExps, t.hExps, h, ranges, proofC[cpu], t.gsize, o,
+				pHMSM)
 			wg2.Done()
 		}(_cpu, _ranges)
 	}
 	wg2.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 	// join 2
 	for cpu := 1; cpu < numcpu; cpu++ {
 		proofC[0].Add(proofC[0], proofC[cpu])
@@ -135,17 +187,78 @@ func GenerateProof(pk *types.Pk, w types.Witness) (*types.Proof, []*big.Int, err
 	return &proof, pubSignals, nil
 }
 
-func calculateH(pk *types.Pk, w types.Witness) []*big.Int {
+// msmG1 computes the multi-scalar multiplication of the points and scalars
+// in the range, using the precomputed tables of the points if not nil, or the
+// algorithm of the options otherwise. Stops when the context is done.
+func msmG1(ctx context.Context, a []*bn256.G1, t []tableG1, k []*big.Int, rng [2]int,
+	qPrev *bn256.G1, tgsize int, o *options, p *progressTracker) *bn256.G1 {
+	if rng[0] == rng[1] {
+		return qPrev
+	}
+	if t != nil {
+		return mulTablesG1(ctx, t[rng[0]/tgsize:(rng[1]+tgsize-1)/tgsize],
+			k[rng[0]:rng[1]], qPrev, tgsize, p)
+	}
+	a, k = a[rng[0]:rng[1]], k[rng[0]:rng[1]]
+	if o.msm == MSMPippenger {
+		return pippengerG1(ctx, a, k, qPrev, p)
+	}
+	// Strauss-Shamir, in blocks of msmBlockSize points
+	for i := 0; i < len(a); i += msmBlockSize {
+		if ctx.Err() != nil {
+			return qPrev
+		}
+		j := i + msmBlockSize
+		if j > len(a) {
+			j = len(a)
+		}
+		qPrev = scalarMultNoDoubleG1(a[i:j], k[i:j], qPrev, o.gsize)
+		p.add(j - i)
+	}
+	return qPrev
+}
+
+// msmG2 computes the multi-scalar multiplication of the points and scalars
+// in the range, using the precomputed tables of the points if not nil, or the
+// algorithm of the options otherwise. Stops when the context is done.
+func msmG2(ctx context.Context, a []*bn256.G2, t []tableG2, k []*big.Int, rng [2]int,
+	qPrev *bn256.G2, tgsize int, o *options, p *progressTracker) *bn256.G2 {
+	if rng[0] == rng[1] {
+		return qPrev
+	}
+	if t != nil {
+		return mulTablesG2(ctx, t[rng[0]/tgsize:(rng[1]+tgsize-1)/tgsize],
+			k[rng[0]:rng[1]], qPrev, tgsize, p)
+	}
+	a, k = a[rng[0]:rng[1]], k[rng[0]:rng[1]]
+	if o.msm == MSMPippenger {
+		return pippengerG2(ctx, a, k, qPrev, p)
+	}
+	// Strauss-Shamir, in blocks of msmBlockSize points
+	for i := 0; i < len(a); i += msmBlockSize {
+		if ctx.Err() != nil {
+			return qPrev
+		}
+		j := i + msmBlockSize
+		if j > len(a) {
+			j = len(a)
+		}
+		qPrev = scalarMultNoDoubleG2(a[i:j], k[i:j], qPrev, o.gsize)
+		p.add(j - i)
+	}
+	return qPrev
+}
+
+func calculateH(ctx context.Context, pk *types.Pk, w types.Witness, numcpu int,
+	p *progressTracker) ([]*big.Int, error) {
 	m := pk.DomainSize
 	polAT := arrayOfZeroes(m)
 	polBT := arrayOfZeroes(m)
 
-	numcpu := runtime.NumCPU()
-
 	var wg1 sync.WaitGroup
 	wg1.Add(2) //nolint:gomnd
 	go func() {
-		for i := 0; i < pk.NVars; i++ {
+		for i := 0; i < pk.NVars && ctx.Err() == nil; i++ {
 			for j := range pk.PolsA[i] {
 				polAT[j] = fAdd(polAT[j], fMul(w[i], pk.PolsA[i][j]))
 			}
@@ -153,7 +266,7 @@ func calculateH(pk *types.Pk, w types.Witness) []*big.Int {
 		wg1.Done()
 	}()
 	go func() {
-		for i := 0; i < pk.NVars; i++ {
+		for i := 0; i < pk.NVars && ctx.Err() == nil; i++ {
 			for j := range pk.PolsB[i] {
 				polBT[j] = fAdd(polBT[j], fMul(w[i], pk.PolsB[i][j]))
 			}
@@ -161,50 +274,75 @@ func calculateH(pk *types.Pk, w types.Witness) []*big.Int {
 		wg1.Done()
 	}()
 	wg1.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.add(1)
 	polATe := utils.BigIntArrayToElementArray(polAT)
 	polBTe := utils.BigIntArrayToElementArray(polBT)
 
-	polASe := ifft(polATe)
-	polBSe := ifft(polBTe)
-
-	r := int(math.Log2(float64(m))) + 1
-	roots := newRootsT()
-	roots.setRoots(r)
+	// coefficients of A and B, to be evaluated on the odd coset
+	polAOdd := copyElements(polATe)
+	polBOdd := copyElements(polBTe)
+	ifft(polAOdd, numcpu)
+	ifft(polBOdd, numcpu)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	p.add(1)
 
-	var wg2 sync.WaitGroup
-	wg2.Add(numcpu)
-	for _cpu, _ranges := range ranges(len(polASe), numcpu) {
-		go func(cpu int, ranges [2]int) {
-			for i := ranges[0]; i < ranges[1]; i++ {
-				polASe[i].Mul(polASe[i], roots.roots[r][i])
-				polBSe[i].Mul(polBSe[i], roots.roots[r][i])
-			}
-			wg2.Done()
-		}(_cpu, _ranges)
+	cosetFFT(polAOdd, numcpu)
+	cosetFFT(polBOdd, numcpu)
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	wg2.Wait()
+	p.add(1)
 
-	polATodd := fft(polASe)
-	polBTodd := fft(polBSe)
+	runRanges(m, numcpu, func(start, end int) {
+		for i := start; i < end; i++ {
+			polATe[i].Mul(polATe[i], polBTe[i])
+			polAOdd[i].Mul(polAOdd[i], polBOdd[i])
+		}
+	})
 
-	polABT := arrayOfZeroesE(len(polASe) * 2) //nolint:gomnd
-	var wg3 sync.WaitGroup
-	wg3.Add(numcpu)
-	for _cpu, _ranges := range ranges(len(polASe), numcpu) {
-		go func(cpu int, ranges [2]int) {
-			for i := ranges[0]; i < ranges[1]; i++ {
-				polABT[2*i].Mul(polATe[i], polBTe[i])
-				polABT[2*i+1].Mul(polATodd[i], polBTodd[i])
-			}
-			wg3.Done()
-		}(_cpu, _ranges)
+	// A·B = L + x^m·H, where L and H have degree < m. As w^m = 1 on the
+	// domain and g^m = -1 on the odd coset, the interpolation of the domain
+	// evaluations is L + H and the one of the odd coset evaluations is L - H
+	ifft(polATe, numcpu)
+	cosetIFFT(polAOdd, numcpu)
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	wg3.Wait()
+	twoInv := new(ff.Element).Inverse(new(ff.Element).SetUint64(2)) //nolint:gomnd
+	runRanges(m, numcpu, func(start, end int) {
+		for i := start; i < end; i++ {
+			polATe[i].Sub(polATe[i], polAOdd[i])
+			polATe[i].Mul(polATe[i], twoInv)
+		}
+	})
+	p.add(1)
 
-	hSeFull := ifft(polABT)
+	return utils.ElementArrayToBigIntArray(polATe), nil
+}
 
-	hSe := hSeFull[m:]
-	return utils.ElementArrayToBigIntArray(hSe)
+// alignedRanges splits n in parts, where the start of each range is a
+// multiple of align
+func alignedRanges(n, parts, align int) [][2]int {
+	if align <= 1 {
+		return ranges(n, parts)
+	}
+	s := ranges((n+align-1)/align, parts)
+	for i := range s {
+		s[i][0] *= align
+		s[i][1] *= align
+		if s[i][0] > n {
+			s[i][0] = n
+		}
+		if s[i][1] > n {
+			s[i][1] = n
+		}
+	}
+	return s
 }
 
 func ranges(n, parts int) [][2]int {
@@ -1,6 +1,7 @@
 package prover
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -10,7 +11,9 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
 	"github.com/vocdoni/go-snark/parsers"
+	"github.com/vocdoni/go-snark/setup"
 	"github.com/vocdoni/go-snark/verifier"
 )
 
@@ -81,6 +84,42 @@ func testCircuitGenerateProof(t *testing.T, circuit string) {
 	// snarkjs verify --vk testdata/circuitX/verification_key.json -p testdata/circuitX/proof.json --pub testdata/circuitX/public.json
 }
 
+func TestGenerateProofWithContext(t *testing.T) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+
+	done := make(map[Phase]int)
+	proof, pubSignals, err := GenerateProofWithContext(context.Background(), pk,
+		testutil.CubicWitness(3), func(phase Phase, d, total int) {
+			assert.True(t, d <= total)
+			done[phase] = d
+		})
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+	assert.Equal(t, 4*pk.NVars-pk.NPublic-1, done[PhaseMSM])
+	assert.Equal(t, hSteps, done[PhaseH])
+	assert.Equal(t, pk.DomainSize, done[PhaseHMSM])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err = GenerateProofWithContext(ctx, pk, testutil.CubicWitness(3), nil)
+	assert.Equal(t, context.Canceled, err)
+
+	// cancel in the middle of the proof generation
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	var phases []Phase
+	_, _, err = GenerateProofWithContext(ctx, pk, testutil.CubicWitness(3),
+		func(phase Phase, d, total int) {
+			phases = append(phases, phase)
+			if phase == PhaseH {
+				cancel()
+			}
+		})
+	assert.Equal(t, context.Canceled, err)
+	assert.NotContains(t, phases, PhaseHMSM)
+}
+
 func BenchmarkGenerateProof(b *testing.B) {
 	// benchmark with a circuit of 10000 constraints
 	provingKeyJSON, err := ioutil.ReadFile("../testdata/circuit5k/proving_key.json")
@@ -0,0 +1,132 @@
+package prover
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+)
+
+var (
+	// ErrWitnessLength is returned when the length of the witness does not
+	// match the NVars of the ProvingKey
+	ErrWitnessLength = errors.New("witness length does not match the proving key nVars")
+	// ErrWitnessOne is returned when the first element of the witness, the
+	// constant signal, is not 1
+	ErrWitnessOne = errors.New("first witness element must be 1")
+	// ErrOutOfField is returned, wrapped in a *FieldError, when a witness
+	// value is not in the [0, R) range of the field
+	ErrOutOfField = errors.New("witness value out of the field")
+	// ErrInconsistentKey is returned when the sizes of the ProvingKey
+	// elements are not consistent between them
+	ErrInconsistentKey = errors.New("inconsistent proving key")
+)
+
+// FieldError is returned when the witness value at Index is not in the field.
+// It matches ErrOutOfField with errors.Is.
+type FieldError struct {
+	Index int
+	Value *big.Int
+}
+
+// Error implements the error interface for FieldError
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: w[%d] = %s", ErrOutOfField, e.Index, e.Value)
+}
+
+// Unwrap returns ErrOutOfField
+func (e *FieldError) Unwrap() error {
+	return ErrOutOfField
+}
+
+// checkPk checks that the sizes of the ProvingKey elements are consistent and
+// that they are not nil, so the proof generation can not index them out of
+// range nor dereference them
+func checkPk(pk *types.Pk) error {
+	if pk == nil {
+		return fmt.Errorf("%w: nil proving key", ErrInconsistentKey)
+	}
+	if pk.NPublic < 0 || pk.NVars < pk.NPublic+1 {
+		return fmt.Errorf("%w: nPublic (%d) must be between 0 and nVars-1 (%d)",
+			ErrInconsistentKey, pk.NPublic, pk.NVars-1)
+	}
+	m := pk.DomainSize
+	if m < 1 || m&(m-1) != 0 || m > 1<<(maxDomainBits-1) {
+		return fmt.Errorf("%w: domain size must be a power of two not greater than 2^%d, got %d",
+			ErrInconsistentKey, maxDomainBits-1, m)
+	}
+	for _, l := range []struct {
+		name string
+		n    int
+	}{
+		{"A", len(pk.A)}, {"B1", len(pk.B1)}, {"B2", len(pk.B2)}, {"C", len(pk.C)},
+		{"PolsA", len(pk.PolsA)}, {"PolsB", len(pk.PolsB)},
+	} {
+		if l.n != pk.NVars {
+			return fmt.Errorf("%w: %s has %d elements, expected nVars (%d)",
+				ErrInconsistentKey, l.name, l.n, pk.NVars)
+		}
+	}
+	if len(pk.HExps) < m {
+		return fmt.Errorf("%w: HExps has %d elements, expected at least the domain size (%d)",
+			ErrInconsistentKey, len(pk.HExps), m)
+	}
+	if pk.VkAlpha1 == nil || pk.VkBeta1 == nil || pk.VkDelta1 == nil ||
+		pk.VkBeta2 == nil || pk.VkDelta2 == nil {
+		return fmt.Errorf("%w: missing verification key points", ErrInconsistentKey)
+	}
+	// nil points would panic in the goroutines of the MSM and the FFT, where
+	// the panic can not be recovered
+	for _, points := range []struct {
+		name string
+		a    []*bn256.G1
+	}{
+		{"A", pk.A}, {"B1", pk.B1}, {"C", pk.C[pk.NPublic+1:]}, {"HExps", pk.HExps[:m]},
+	} {
+		for i, p := range points.a {
+			if p == nil {
+				return fmt.Errorf("%w: %s has the nil point %d", ErrInconsistentKey,
+					points.name, i)
+			}
+		}
+	}
+	for i, p := range pk.B2 {
+		if p == nil {
+			return fmt.Errorf("%w: B2 has the nil point %d", ErrInconsistentKey, i)
+		}
+	}
+	for _, pols := range [][]map[int]*big.Int{pk.PolsA, pk.PolsB} {
+		for i := range pols {
+			for j, v := range pols[i] {
+				if j < 0 || j >= m {
+					return fmt.Errorf("%w: polynomial %d has the coefficient %d out of"+
+						" the domain (%d)", ErrInconsistentKey, i, j, m)
+				}
+				if v == nil {
+					return fmt.Errorf("%w: polynomial %d has the nil coefficient %d",
+						ErrInconsistentKey, i, j)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkWitness checks that the witness has the ProvingKey NVars values in the
+// field, being the first one 1
+func checkWitness(pk *types.Pk, w types.Witness) error {
+	if len(w) != pk.NVars {
+		return fmt.Errorf("%w: got %d, expected %d", ErrWitnessLength, len(w), pk.NVars)
+	}
+	for i, v := range w {
+		if v == nil || v.Sign() < 0 || v.Cmp(types.R) >= 0 {
+			return &FieldError{Index: i, Value: v}
+		}
+	}
+	if w[0].Cmp(big.NewInt(1)) != 0 {
+		return fmt.Errorf("%w: got %s", ErrWitnessOne, w[0])
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package prover
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/types"
+)
+
+func TestGenerateProofInvalidWitness(t *testing.T) {
+	pk, _, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+
+	w := testutil.CubicWitness(3)
+	_, _, err = GenerateProof(pk, w[:5])
+	assert.True(t, errors.Is(err, ErrWitnessLength))
+	_, _, err = GenerateProof(pk, append(w, big.NewInt(1)))
+	assert.True(t, errors.Is(err, ErrWitnessLength))
+
+	w = testutil.CubicWitness(3)
+	w[0] = big.NewInt(2)
+	_, _, err = GenerateProof(pk, w)
+	assert.True(t, errors.Is(err, ErrWitnessOne))
+
+	for _, v := range []*big.Int{nil, big.NewInt(-1), types.R} {
+		w = testutil.CubicWitness(3)
+		w[4] = v
+		_, _, err = GenerateProof(pk, w)
+		assert.True(t, errors.Is(err, ErrOutOfField))
+		var fieldErr *FieldError
+		require.True(t, errors.As(err, &fieldErr))
+		assert.Equal(t, 4, fieldErr.Index)
+	}
+}
+
+func TestGenerateProofInconsistentKey(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		modify func(pk *types.Pk)
+	}{
+		{"nil", nil},
+		{"nPublic", func(pk *types.Pk) { pk.NPublic = pk.NVars }},
+		{"domain size", func(pk *types.Pk) { pk.DomainSize = 6 }},
+		{"A", func(pk *types.Pk) { pk.A = pk.A[1:] }},
+		{"B2", func(pk *types.Pk) { pk.B2 = append(pk.B2, new(bn256.G2)) }},
+		{"C", func(pk *types.Pk) { pk.C = pk.C[1:] }},
+		{"PolsA", func(pk *types.Pk) { pk.PolsA = pk.PolsA[1:] }},
+		{"PolsB", func(pk *types.Pk) {
+			pk.PolsB[2] = map[int]*big.Int{pk.DomainSize: big.NewInt(1)}
+		}},
+		{"HExps", func(pk *types.Pk) { pk.HExps = pk.HExps[:pk.DomainSize-1] }},
+		{"VkDelta2", func(pk *types.Pk) { pk.VkDelta2 = nil }},
+		{"nil A point", func(pk *types.Pk) { pk.A[1] = nil }},
+		{"nil B2 point", func(pk *types.Pk) { pk.B2[0] = nil }},
+		{"nil C point", func(pk *types.Pk) { pk.C[pk.NVars-1] = nil }},
+		{"nil HExps point", func(pk *types.Pk) { pk.HExps[pk.DomainSize-1] = nil }},
+		{"nil coefficient", func(pk *types.Pk) {
+			pk.PolsA[2] = map[int]*big.Int{0: nil}
+		}},
+	} {
+		pk, _, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+		require.Nil(t, err)
+		if tc.modify == nil {
+			pk = nil
+		} else {
+			tc.modify(pk)
+		}
+		_, _, err = GenerateProof(pk, testutil.CubicWitness(3))
+		assert.True(t, errors.Is(err, ErrInconsistentKey), tc.name)
+		_, err = NewProver(pk, 4)
+		assert.True(t, errors.Is(err, ErrInconsistentKey), tc.name)
+	}
+}
+
+func TestCheckPkModified(t *testing.T) {
+	pk, _, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	_, _, err = GenerateProof(pk, testutil.CubicWitness(3))
+	require.Nil(t, err)
+
+	// the key is checked again on each call, so a key modified after a proof
+	// is rejected instead of panicking
+	a := pk.A
+	pk.A = pk.A[:len(pk.A)-1]
+	_, _, err = GenerateProof(pk, testutil.CubicWitness(3))
+	assert.True(t, errors.Is(err, ErrInconsistentKey))
+	pk.A = a
+	pk.B1[1] = nil
+	results := GenerateProofs(context.Background(), pk, []types.Witness{testutil.CubicWitness(3)}, 1)
+	assert.True(t, errors.Is(results[0].Err, ErrInconsistentKey))
+}
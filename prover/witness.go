@@ -0,0 +1,62 @@
+package prover
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/vocdoni/go-snark/types"
+)
+
+// ConstraintError is returned when the witness does not satisfy a constraint
+// of the R1CS, containing the index of the constraint and the evaluation of
+// its linear combinations
+type ConstraintError struct {
+	Index int
+	A     *big.Int
+	B     *big.Int
+	C     *big.Int
+}
+
+// Error implements the error interface for ConstraintError
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("constraint %d not satisfied: A·w * B·w != C·w (%s * %s != %s)",
+		e.Index, e.A.String(), e.B.String(), e.C.String())
+}
+
+// CheckWitness checks that the witness satisfies all the constraints of the
+// R1CS, returning a *ConstraintError for the first unsatisfied constraint
+func CheckWitness(r1cs *types.R1CS, w types.Witness) error {
+	if len(w) != r1cs.NVars {
+		return fmt.Errorf("witness length (%d) does not match R1CS nVars (%d)",
+			len(w), r1cs.NVars)
+	}
+	for i, c := range r1cs.Constraints {
+		a, err := evalLinearCombination(c.A, w)
+		if err != nil {
+			return err
+		}
+		b, err := evalLinearCombination(c.B, w)
+		if err != nil {
+			return err
+		}
+		cw, err := evalLinearCombination(c.C, w)
+		if err != nil {
+			return err
+		}
+		if fMul(a, b).Cmp(cw) != 0 {
+			return &ConstraintError{Index: i, A: a, B: b, C: cw}
+		}
+	}
+	return nil
+}
+
+func evalLinearCombination(lc types.LinearCombination, w types.Witness) (*big.Int, error) {
+	r := big.NewInt(0)
+	for i, v := range lc {
+		if i < 0 || i >= len(w) {
+			return nil, fmt.Errorf("linear combination references wire %d out of range", i)
+		}
+		r = fAdd(r, fMul(v, w[i]))
+	}
+	return r, nil
+}
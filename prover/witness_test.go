@@ -0,0 +1,48 @@
+package prover
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+func TestCheckWitness(t *testing.T) {
+	r1cs := testutil.CubicR1CS()
+	assert.Nil(t, CheckWitness(r1cs, testutil.CubicWitness(3)))
+
+	w := testutil.CubicWitness(3)
+	w[4] = big.NewInt(28)
+	err := CheckWitness(r1cs, w)
+	var cErr *ConstraintError
+	require.True(t, errors.As(err, &cErr))
+	assert.Equal(t, 1, cErr.Index)
+	assert.Equal(t, big.NewInt(9), cErr.A)
+	assert.Equal(t, big.NewInt(3), cErr.B)
+	assert.Equal(t, big.NewInt(28), cErr.C)
+
+	assert.NotNil(t, CheckWitness(r1cs, w[:5]))
+}
+
+func TestGenerateCheckedProof(t *testing.T) {
+	r1cs := testutil.CubicR1CS()
+	pk, vk, err := setup.GenerateTrustedSetup(r1cs)
+	require.Nil(t, err)
+
+	proof, pubSignals, err := GenerateCheckedProof(pk, r1cs, testutil.CubicWitness(3))
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	w := testutil.CubicWitness(3)
+	w[1] = big.NewInt(36)
+	proof, _, err = GenerateCheckedProof(pk, r1cs, w)
+	var cErr *ConstraintError
+	require.True(t, errors.As(err, &cErr))
+	assert.Equal(t, 3, cErr.Index)
+	assert.Nil(t, proof)
+}
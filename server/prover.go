@@ -0,0 +1,421 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vocdoni/go-snark/parsers"
+	"github.com/vocdoni/go-snark/prover"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// DefaultMaxWitnessSize is the default maximum size in bytes of the body of a
+// witness submission
+const DefaultMaxWitnessSize = 64 << 20
+
+// DefaultJobRetention is the default time that the finished jobs are kept
+const DefaultJobRetention = 10 * time.Minute
+
+// JobState is the state of a proof generation job
+type JobState string
+
+// States of the jobs
+const (
+	JobQueued    JobState = "queued"
+	JobRunning   JobState = "running"
+	JobDone      JobState = "done"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+// Error codes of the prover responses
+const (
+	CodeQueueFull   = "queue_full"
+	CodeUnknownJob  = "unknown_job"
+	CodeJobFinished = "job_finished"
+	CodeInternal    = "internal"
+)
+
+// JobStatus is the body of the responses of the jobs. Proof and Public are
+// only set when the State is JobDone, in the snarkjs JSON formats, and Error
+// when it is JobFailed or JobCancelled.
+type JobStatus struct {
+	ID       string          `json:"id"`
+	Key      string          `json:"key"`
+	State    JobState        `json:"state"`
+	Phase    string          `json:"phase,omitempty"`
+	Progress float64         `json:"progress"`
+	Error    string          `json:"error,omitempty"`
+	Proof    json.RawMessage `json:"proof,omitempty"`
+	Public   []string        `json:"public,omitempty"`
+	Created  time.Time       `json:"created"`
+	Started  *time.Time      `json:"started,omitempty"`
+	Finished *time.Time      `json:"finished,omitempty"`
+}
+
+// ProvingKeyInfo describes a proving key of the server
+type ProvingKeyInfo struct {
+	Name      string `json:"name"`
+	NVars     int    `json:"nVars"`
+	NPublic   int    `json:"nPublic"`
+	ProvePath string `json:"provePath"`
+}
+
+type job struct {
+	// status is protected by the mutex of the ProverServer
+	status JobStatus
+	key    string
+	w      types.Witness
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// ProverServer is an http.Handler that generates proofs of a set of named
+// proving keys, which are kept in memory. It exposes the endpoints:
+//
+//	GET /keys: the list of ProvingKeyInfo of the proving keys
+//	POST /prove/{name}: queues the proof generation of the JSON witness with
+//	the key name, returning the JobStatus with the status 202
+//	GET /jobs/{id}: the JobStatus of the job
+//	DELETE /jobs/{id}: cancels the job, returning its JobStatus
+//
+// The jobs are generated by a fixed number of workers, and the submissions
+// beyond the queue limit are rejected with the status 503. The finished jobs
+// are kept during the retention time.
+type ProverServer struct {
+	pks            map[string]*types.Pk
+	maxRequestSize int64
+	retention      time.Duration
+	maxQueue       int
+	mux            *http.ServeMux
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// queued signals the workers when a job is queued or the server closed
+	queued *sync.Cond
+	// queue contains the jobs not yet running, being the cancelled ones
+	// removed from it, so that they do not count in the queue limit
+	queue []*job
+	jobs  map[string]*job
+}
+
+// NewProverServer creates a ProverServer for the named proving keys, which are
+// checked once, and starts its workers. workers is the number of proofs
+// generated concurrently (1 if < 1), maxQueue the maximum number of queued
+// jobs not yet running (workers if < 1) and maxRequestSize the maximum size of
+// a witness submission (DefaultMaxWitnessSize if < 1). Close must be called to
+// stop the workers.
+func NewProverServer(pks map[string]*types.Pk, workers, maxQueue int,
+	maxRequestSize int64) (*ProverServer, error) {
+	if len(pks) == 0 {
+		return nil, fmt.Errorf("no proving keys")
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	if maxQueue < 1 {
+		maxQueue = workers
+	}
+	if maxRequestSize < 1 {
+		maxRequestSize = DefaultMaxWitnessSize
+	}
+	for name, pk := range pks {
+		if name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid proving key name: %q", name)
+		}
+		if pk == nil || pk.NVars < pk.NPublic+1 || pk.NPublic < 0 {
+			return nil, fmt.Errorf("invalid proving key %s", name)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ProverServer{
+		pks:            pks,
+		maxRequestSize: maxRequestSize,
+		retention:      DefaultJobRetention,
+		maxQueue:       maxQueue,
+		mux:            http.NewServeMux(),
+		ctx:            ctx,
+		cancel:         cancel,
+		jobs:           make(map[string]*job),
+	}
+	s.queued = sync.NewCond(&s.mu)
+	s.mux.HandleFunc("/keys", s.handleKeys)
+	s.mux.HandleFunc("/prove/", s.handleProve)
+	s.mux.HandleFunc("/jobs/", s.handleJob)
+
+	// each worker uses a share of the CPUs for its proof generation
+	numWorkers := runtime.NumCPU() / workers
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.worker(numWorkers)
+	}
+	return s, nil
+}
+
+// Close cancels the queued and running jobs and waits for the workers to stop.
+// It must be called once the server does not receive more requests.
+func (s *ProverServer) Close() {
+	s.mu.Lock()
+	s.cancel()
+	s.queued.Broadcast()
+	s.mu.Unlock()
+	s.wg.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.queue {
+		s.finish(j, context.Canceled)
+		j.w = nil
+	}
+	s.queue = nil
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *ProverServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *ProverServer) worker(numWorkers int) {
+	defer s.wg.Done()
+	for {
+		j := s.next()
+		if j == nil {
+			return
+		}
+		s.run(j, numWorkers)
+	}
+}
+
+// next waits for the next queued job and removes it from the queue, returning
+// nil when the server is closed
+func (s *ProverServer) next() *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queue) == 0 && s.ctx.Err() == nil {
+		s.queued.Wait()
+	}
+	if s.ctx.Err() != nil {
+		return nil
+	}
+	j := s.queue[0]
+	s.queue[0] = nil
+	s.queue = s.queue[1:]
+	return j
+}
+
+// dequeue removes the job from the queue. It must be called with the mutex
+// locked.
+func (s *ProverServer) dequeue(j *job) {
+	for i := range s.queue {
+		if s.queue[i] == j {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *ProverServer) run(j *job, numWorkers int) {
+	defer j.cancel()
+	s.mu.Lock()
+	if j.status.State != JobQueued {
+		s.mu.Unlock()
+		return
+	}
+	now := time.Now()
+	j.status.State = JobRunning
+	j.status.Started = &now
+	s.mu.Unlock()
+
+	progress := func(phase prover.Phase, done, total int) {
+		if total == 0 {
+			return
+		}
+		s.mu.Lock()
+		j.status.Phase = phase.String()
+		// the phases are reported in order, each one being a third
+		j.status.Progress = (float64(phase) + float64(done)/float64(total)) / 3 //nolint:gomnd
+		s.mu.Unlock()
+	}
+	proof, public, err := prover.GenerateProofWithOptions(j.ctx, s.pks[j.key], j.w,
+		prover.WithNumWorkers(numWorkers), prover.WithProgress(progress))
+	var proofJSON []byte
+	if err == nil {
+		proofJSON, err = parsers.ProofToJSON(proof)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finish(j, err)
+	if err == nil {
+		j.status.Progress = 1
+		j.status.Proof = proofJSON
+		j.status.Public = parsers.ArrayBigIntToString(public)
+	}
+	j.w = nil
+}
+
+// finish sets the final state of the job from the error of the proof
+// generation. It must be called with the mutex locked.
+func (s *ProverServer) finish(j *job, err error) {
+	now := time.Now()
+	j.status.Finished = &now
+	switch {
+	case err == nil:
+		j.status.State = JobDone
+	case errors.Is(err, context.Canceled):
+		j.status.State = JobCancelled
+		j.status.Error = err.Error()
+	default:
+		j.status.State = JobFailed
+		j.status.Error = err.Error()
+	}
+}
+
+// removeExpired removes the jobs finished before the retention time. It must
+// be called with the mutex locked.
+func (s *ProverServer) removeExpired() {
+	limit := time.Now().Add(-s.retention)
+	for id, j := range s.jobs {
+		if j.status.Finished != nil && j.status.Finished.Before(limit) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (s *ProverServer) handleKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed,
+			"method not allowed: "+r.Method)
+		return
+	}
+	keys := make([]ProvingKeyInfo, 0, len(s.pks))
+	for name, pk := range s.pks {
+		keys = append(keys, ProvingKeyInfo{Name: name, NVars: pk.NVars, NPublic: pk.NPublic,
+			ProvePath: "/prove/" + name})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (s *ProverServer) handleProve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed,
+			"method not allowed: "+r.Method)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/prove/")
+	pk, ok := s.pks[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeUnknownKey, "unknown proving key: "+name)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, s.maxRequestSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if int64(len(body)) > s.maxRequestSize {
+		writeError(w, http.StatusRequestEntityTooLarge, CodeRequestTooLarge,
+			fmt.Sprintf("request body larger than %d bytes", s.maxRequestSize))
+		return
+	}
+	wit, err := parsers.ParseWitness(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid witness: "+err.Error())
+		return
+	}
+	if len(wit) != pk.NVars {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest,
+			fmt.Sprintf("%s: got %d, expected %d", prover.ErrWitnessLength, len(wit), pk.NVars))
+		return
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, CodeInternal, err.Error())
+		return
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	j := &job{
+		status: JobStatus{ID: id, Key: name, State: JobQueued, Created: time.Now()},
+		key:    name,
+		w:      wit,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) >= s.maxQueue {
+		cancel()
+		writeError(w, http.StatusServiceUnavailable, CodeQueueFull, "proof queue is full")
+		return
+	}
+	s.queue = append(s.queue, j)
+	s.queued.Signal()
+	s.removeExpired()
+	s.jobs[id] = j
+	writeJSON(w, http.StatusAccepted, j.status)
+}
+
+func (s *ProverServer) handleJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeUnknownJob, "unknown job: "+id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, j.status)
+	case http.MethodDelete:
+		switch j.status.State {
+		case JobQueued:
+			// removed from the queue, freeing its place for new jobs
+			s.dequeue(j)
+			s.finish(j, context.Canceled)
+			j.cancel()
+			j.w = nil
+		case JobRunning:
+			// the worker sets the final state when the generation stops
+			j.cancel()
+		default:
+			writeError(w, http.StatusConflict, CodeJobFinished,
+				"job already finished: "+string(j.status.State))
+			return
+		}
+		writeJSON(w, http.StatusOK, j.status)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed,
+			"method not allowed: "+r.Method)
+	}
+}
+
+func newJobID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/parsers"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/types"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+func witnessJSON(t *testing.T, w types.Witness) []byte {
+	b, err := json.Marshal(parsers.ArrayBigIntToString(w))
+	require.Nil(t, err)
+	return b
+}
+
+func jobRequest(t *testing.T, s http.Handler, method, id string) (int, JobStatus) {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(method, "/jobs/"+id, nil))
+	var status JobStatus
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	return rec.Code, status
+}
+
+func TestProverServer(t *testing.T) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+
+	s, err := NewProverServer(map[string]*types.Pk{"cubic": pk}, 2, 4, 0)
+	require.Nil(t, err)
+	defer s.Close()
+
+	rec := post(s, "/prove/cubic", witnessJSON(t, testutil.CubicWitness(3)))
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	var status JobStatus
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	assert.Equal(t, "cubic", status.Key)
+	assert.NotEmpty(t, status.ID)
+
+	for i := 0; i < 500 && (status.State == JobQueued || status.State == JobRunning); i++ {
+		time.Sleep(10 * time.Millisecond)
+		var code int
+		code, status = jobRequest(t, s, http.MethodGet, status.ID)
+		assert.Equal(t, http.StatusOK, code)
+	}
+	require.Equal(t, JobDone, status.State, status.Error)
+	assert.Equal(t, 1.0, status.Progress)
+	assert.NotNil(t, status.Started)
+	assert.NotNil(t, status.Finished)
+
+	proof, err := parsers.ParseProof(status.Proof)
+	require.Nil(t, err)
+	public, err := parsers.ParsePublicSignals(witnessJSON(t, testutil.CubicWitness(3)[1:2]))
+	require.Nil(t, err)
+	assert.Equal(t, []string{"35"}, status.Public)
+	assert.Nil(t, verifier.CheckProof(vk, proof, public))
+
+	code, _ := jobRequest(t, s, http.MethodDelete, status.ID)
+	assert.Equal(t, http.StatusConflict, code)
+
+	// a witness value out of the field fails the proof generation
+	w := testutil.CubicWitness(3)
+	w[2] = types.R
+	rec = post(s, "/prove/cubic", witnessJSON(t, w))
+	require.Equal(t, http.StatusAccepted, rec.Code)
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &status))
+	for i := 0; i < 500 && (status.State == JobQueued || status.State == JobRunning); i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, status = jobRequest(t, s, http.MethodGet, status.ID)
+	}
+	assert.Equal(t, JobFailed, status.State)
+	assert.NotEmpty(t, status.Error)
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/keys", nil))
+	var keys []ProvingKeyInfo
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &keys))
+	assert.Equal(t, []ProvingKeyInfo{{Name: "cubic", NVars: 6, NPublic: 1,
+		ProvePath: "/prove/cubic"}}, keys)
+}
+
+func TestProverServerQueue(t *testing.T) {
+	pk, _, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+
+	s, err := NewProverServer(map[string]*types.Pk{"cubic": pk}, 1, 2, 1024)
+	require.Nil(t, err)
+	// without workers, the jobs stay in the queue
+	s.Close()
+
+	body := witnessJSON(t, testutil.CubicWitness(3))
+	var ids []string
+	for i := 0; i < 2; i++ {
+		rec := post(s, "/prove/cubic", body)
+		require.Equal(t, http.StatusAccepted, rec.Code)
+		var status JobStatus
+		require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &status))
+		assert.Equal(t, JobQueued, status.State)
+		ids = append(ids, status.ID)
+	}
+	rec := post(s, "/prove/cubic", body)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	var errResp ErrorResponse
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+	assert.Equal(t, CodeQueueFull, errResp.Code)
+
+	code, status := jobRequest(t, s, http.MethodDelete, ids[0])
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, JobCancelled, status.State)
+	code, _ = jobRequest(t, s, http.MethodDelete, ids[0])
+	assert.Equal(t, http.StatusConflict, code)
+	// the cancelled job does not count in the queue limit
+	rec = post(s, "/prove/cubic", body)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	rec = post(s, "/prove/cubic", body)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	code, status = jobRequest(t, s, http.MethodGet, ids[1])
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, JobQueued, status.State)
+
+	for _, c := range []struct {
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{http.MethodPost, "/prove/cubic", "[", http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/prove/cubic", `["1", "2"]`, http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/prove/cubic", string(body) + strings.Repeat(" ", 1024),
+			http.StatusRequestEntityTooLarge, CodeRequestTooLarge},
+		{http.MethodPost, "/prove/other", string(body), http.StatusNotFound, CodeUnknownKey},
+		{http.MethodGet, "/prove/cubic", "", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{http.MethodGet, "/jobs/unknown", "", http.StatusNotFound, CodeUnknownJob},
+		{http.MethodPut, "/jobs/" + ids[1], "", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, bytes.NewReader([]byte(c.body))))
+		assert.Equal(t, c.status, rec.Code, c.path+" "+c.body)
+		var resp ErrorResponse
+		require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, c.code, resp.Code)
+	}
+
+	_, err = NewProverServer(nil, 0, 0, 0)
+	assert.NotNil(t, err)
+	_, err = NewProverServer(map[string]*types.Pk{"a/b": pk}, 0, 0, 0)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,220 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/vocdoni/go-snark/parsers"
+	"github.com/vocdoni/go-snark/types"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+// DefaultMaxRequestSize is the default maximum size in bytes of the body of
+// a request
+const DefaultMaxRequestSize = 1 << 20
+
+// VerifyRequest is the body of a verification request, with the proof and the
+// public signals in the snarkjs JSON formats
+type VerifyRequest struct {
+	Proof  json.RawMessage `json:"proof"`
+	Public json.RawMessage `json:"public"`
+}
+
+// VerifyResponse is the body of the response of a verification request. When
+// the proof is not valid, Error contains the reason and Code one of the
+// values of ErrorCode.
+type VerifyResponse struct {
+	Valid bool   `json:"valid"`
+	Code  string `json:"code,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ErrorResponse is the body of the response of a failed request
+type ErrorResponse struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// KeyInfo describes a verification key of the server
+type KeyInfo struct {
+	Name       string `json:"name"`
+	NPublic    int    `json:"nPublic"`
+	VerifyPath string `json:"verifyPath"`
+}
+
+// Error codes of the responses
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeRequestTooLarge  = "request_too_large"
+	CodeUnknownKey       = "unknown_key"
+	CodeBusy             = "busy"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeInvalidProof     = "invalid_proof"
+	CodeInputCount       = "input_count"
+	CodeInputOutOfField  = "input_out_of_field"
+	CodePairing          = "pairing"
+)
+
+// ErrorCode returns the code of the response of a verification error
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, verifier.ErrInvalidProof):
+		return CodeInvalidProof
+	case errors.Is(err, verifier.ErrInputCount):
+		return CodeInputCount
+	case errors.Is(err, verifier.ErrInputOutOfField):
+		return CodeInputOutOfField
+	case errors.Is(err, verifier.ErrPairing):
+		return CodePairing
+	}
+	return CodeInvalidRequest
+}
+
+// VerifierServer is an http.Handler that verifies proofs of a set of named
+// verification keys. It exposes the endpoints:
+//
+//	GET /keys: the list of KeyInfo of the verification keys
+//	POST /verify/{name}: verifies the VerifyRequest proof with the key name,
+//	returning a VerifyResponse
+//
+// Verifications beyond the concurrency limit are rejected with the status
+// 503, and bodies larger than the size limit with the status 413.
+type VerifierServer struct {
+	keys           map[string]*verifier.PreparedVk
+	nPublic        map[string]int
+	maxRequestSize int64
+	sem            chan struct{}
+	mux            *http.ServeMux
+}
+
+// NewVerifierServer creates a VerifierServer for the named verification keys,
+// which are validated and prepared once. maxRequestSize is the maximum size
+// of a request body (DefaultMaxRequestSize if < 1) and maxConcurrent the
+// maximum number of concurrent verifications (runtime.NumCPU() if < 1).
+func NewVerifierServer(vks map[string]*types.Vk, maxRequestSize int64,
+	maxConcurrent int) (*VerifierServer, error) {
+	if len(vks) == 0 {
+		return nil, fmt.Errorf("no verification keys")
+	}
+	if maxRequestSize < 1 {
+		maxRequestSize = DefaultMaxRequestSize
+	}
+	if maxConcurrent < 1 {
+		maxConcurrent = runtime.NumCPU()
+	}
+	s := &VerifierServer{
+		keys:           make(map[string]*verifier.PreparedVk, len(vks)),
+		nPublic:        make(map[string]int, len(vks)),
+		maxRequestSize: maxRequestSize,
+		sem:            make(chan struct{}, maxConcurrent),
+		mux:            http.NewServeMux(),
+	}
+	for name, vk := range vks {
+		if name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid verification key name: %q", name)
+		}
+		pvk, err := verifier.NewPreparedVk(vk)
+		if err != nil {
+			return nil, fmt.Errorf("verification key %s: %w", name, err)
+		}
+		s.keys[name] = pvk
+		s.nPublic[name] = len(vk.IC) - 1
+	}
+	s.mux.HandleFunc("/keys", s.handleKeys)
+	s.mux.HandleFunc("/verify/", s.handleVerify)
+	return s, nil
+}
+
+// ServeHTTP implements the http.Handler interface
+func (s *VerifierServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *VerifierServer) handleKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed,
+			"method not allowed: "+r.Method)
+		return
+	}
+	keys := make([]KeyInfo, 0, len(s.keys))
+	for name := range s.keys {
+		keys = append(keys, KeyInfo{Name: name, NPublic: s.nPublic[name],
+			VerifyPath: "/verify/" + name})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	writeJSON(w, http.StatusOK, keys)
+}
+
+func (s *VerifierServer) handleVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed,
+			"method not allowed: "+r.Method)
+		return
+	}
+	name := strings.TrimPrefix(r.URL.Path, "/verify/")
+	pvk, ok := s.keys[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, CodeUnknownKey, "unknown verification key: "+name)
+		return
+	}
+
+	// the body is read and parsed before taking a verification slot, so that
+	// slow uploads do not hold them
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, s.maxRequestSize+1))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	if int64(len(body)) > s.maxRequestSize {
+		writeError(w, http.StatusRequestEntityTooLarge, CodeRequestTooLarge,
+			fmt.Sprintf("request body larger than %d bytes", s.maxRequestSize))
+		return
+	}
+	var req VerifyRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	proof, err := parsers.ParseProof(req.Proof)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest, "invalid proof: "+err.Error())
+		return
+	}
+	public, err := parsers.ParsePublicSignals(req.Public)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, CodeInvalidRequest,
+			"invalid public signals: "+err.Error())
+		return
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+	default:
+		writeError(w, http.StatusServiceUnavailable, CodeBusy, "too many concurrent verifications")
+		return
+	}
+	err = pvk.CheckProof(proof, public)
+	<-s.sem
+	if err != nil {
+		writeJSON(w, http.StatusOK, VerifyResponse{Code: ErrorCode(err), Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, VerifyResponse{Valid: true})
+}
+
+func writeError(w http.ResponseWriter, status int, code, msg string) {
+	writeJSON(w, status, ErrorResponse{Code: code, Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/parsers"
+	"github.com/vocdoni/go-snark/prover"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/types"
+)
+
+func verifyRequest(t *testing.T, proof *types.Proof, public []*big.Int) []byte {
+	proofJSON, err := parsers.ProofToJSON(proof)
+	require.Nil(t, err)
+	publicJSON, err := json.Marshal(parsers.ArrayBigIntToString(public))
+	require.Nil(t, err)
+	body, err := json.Marshal(VerifyRequest{Proof: proofJSON, Public: publicJSON})
+	require.Nil(t, err)
+	return body
+}
+
+func post(s http.Handler, path string, body []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	return rec
+}
+
+func TestVerifierServer(t *testing.T) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	proof, public, err := prover.GenerateProof(pk, testutil.CubicWitness(3))
+	require.Nil(t, err)
+
+	s, err := NewVerifierServer(map[string]*types.Vk{"cubic": vk}, 4096, 2)
+	require.Nil(t, err)
+
+	var resp VerifyResponse
+	rec := post(s, "/verify/cubic", verifyRequest(t, proof, public))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, VerifyResponse{Valid: true}, resp)
+
+	for _, c := range []struct {
+		public []*big.Int
+		code   string
+	}{
+		{[]*big.Int{big.NewInt(36)}, CodePairing},
+		{[]*big.Int{public[0], public[0]}, CodeInputCount},
+		{[]*big.Int{types.R}, CodeInputOutOfField},
+	} {
+		resp = VerifyResponse{}
+		rec = post(s, "/verify/cubic", verifyRequest(t, proof, c.public))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.False(t, resp.Valid)
+		assert.Equal(t, c.code, resp.Code)
+		assert.NotEmpty(t, resp.Error)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/keys", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var keys []KeyInfo
+	require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &keys))
+	assert.Equal(t, []KeyInfo{{Name: "cubic", NPublic: 1, VerifyPath: "/verify/cubic"}}, keys)
+}
+
+func TestVerifierServerErrors(t *testing.T) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	proof, public, err := prover.GenerateProof(pk, testutil.CubicWitness(3))
+	require.Nil(t, err)
+	body := verifyRequest(t, proof, public)
+
+	s, err := NewVerifierServer(map[string]*types.Vk{"cubic": vk}, 4096, 2)
+	require.Nil(t, err)
+
+	for _, c := range []struct {
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{http.MethodPost, "/verify/cubic", "{", http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/verify/cubic", `{"proof": {}, "public": ["1"]}`,
+			http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/verify/cubic", strings.Replace(string(body), `"public":["`,
+			`"public":["x`, 1), http.StatusBadRequest, CodeInvalidRequest},
+		{http.MethodPost, "/verify/cubic", string(body) + strings.Repeat(" ", 4096),
+			http.StatusRequestEntityTooLarge, CodeRequestTooLarge},
+		{http.MethodPost, "/verify/other", string(body), http.StatusNotFound, CodeUnknownKey},
+		{http.MethodGet, "/verify/cubic", "", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+		{http.MethodPost, "/keys", "", http.StatusMethodNotAllowed, CodeMethodNotAllowed},
+	} {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		assert.Equal(t, c.status, rec.Code, c.path+" "+c.body)
+		var resp ErrorResponse
+		require.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		assert.Equal(t, c.code, resp.Code)
+	}
+
+	// the verifications beyond the concurrency limit are rejected
+	s.sem <- struct{}{}
+	s.sem <- struct{}{}
+	rec := post(s, "/verify/cubic", body)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	// the requests are read and parsed without taking a verification slot
+	rec = post(s, "/verify/cubic", []byte("{"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	<-s.sem
+	rec = post(s, "/verify/cubic", body)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	_, err = NewVerifierServer(nil, 0, 0)
+	assert.NotNil(t, err)
+	_, err = NewVerifierServer(map[string]*types.Vk{"a/b": vk}, 0, 0)
+	assert.NotNil(t, err)
+	_, err = NewVerifierServer(map[string]*types.Vk{"cubic": {}}, 0, 0)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,287 @@
+package setup
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/internal/parallel"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// Toxic contains the toxic waste of the trusted setup. Anyone knowing these
+// values can generate false proofs, so they must be discarded once the keys
+// have been generated.
+type Toxic struct {
+	T      *big.Int
+	KAlpha *big.Int
+	KBeta  *big.Int
+	KGamma *big.Int
+	KDelta *big.Int
+}
+
+func randBigInt() (*big.Int, error) {
+	for {
+		r, err := rand.Int(rand.Reader, types.R)
+		if err != nil {
+			return nil, err
+		}
+		if r.Sign() != 0 {
+			return r, nil
+		}
+	}
+}
+
+// NewToxic generates new random toxic waste
+func NewToxic() (*Toxic, error) {
+	var toxic Toxic
+	var err error
+	for _, v := range []**big.Int{&toxic.T, &toxic.KAlpha, &toxic.KBeta,
+		&toxic.KGamma, &toxic.KDelta} {
+		*v, err = randBigInt()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &toxic, nil
+}
+
+// GenerateTrustedSetup generates the ProvingKey and the VerificationKey of
+// the given R1CS using new random toxic waste, which is discarded afterwards
+func GenerateTrustedSetup(r1cs *types.R1CS) (*types.Pk, *types.Vk, error) {
+	toxic, err := NewToxic()
+	if err != nil {
+		return nil, nil, err
+	}
+	return GenerateTrustedSetupWithToxic(r1cs, toxic)
+}
+
+// GenerateTrustedSetupWithToxic generates the ProvingKey and the
+// VerificationKey of the given R1CS using the given toxic waste. The keys are
+// compatible with the ones generated by snarkjs, where the input consistency
+// constraints (input_i * 0 = 0) are appended after the circuit constraints.
+func GenerateTrustedSetupWithToxic(r1cs *types.R1CS, toxic *Toxic) (*types.Pk,
+	*types.Vk, error) {
+	if err := checkR1CS(r1cs); err != nil {
+		return nil, nil, err
+	}
+	if err := checkToxic(toxic); err != nil {
+		return nil, nil, err
+	}
+
+	var pk types.Pk
+	pk.NVars = r1cs.NVars
+	pk.NPublic = r1cs.NPublic
+
+	nRows := len(r1cs.Constraints) + r1cs.NPublic + 1
+	bits := 1
+	for 1<<bits < nRows {
+		bits++
+	}
+	pk.DomainSize = 1 << bits
+
+	pk.PolsA, pk.PolsB = calculatePolynomials(r1cs)
+	polsC := make([]map[int]*big.Int, r1cs.NVars)
+	for i := 0; i < r1cs.NVars; i++ {
+		polsC[i] = make(map[int]*big.Int)
+	}
+	for c, constraint := range r1cs.Constraints {
+		for s, v := range constraint.C {
+			polsC[s][c] = new(big.Int).Mod(v, types.R)
+		}
+	}
+
+	zT := fSub(fExp(toxic.T, big.NewInt(int64(pk.DomainSize))), big.NewInt(1))
+	if zT.Sign() == 0 {
+		return nil, nil, fmt.Errorf("toxic T can not be a root of unity of the domain")
+	}
+	u := lagrangeAt(bits, toxic.T, zT)
+	aT := evaluatePolynomials(pk.PolsA, u)
+	bT := evaluatePolynomials(pk.PolsB, u)
+	cT := evaluatePolynomials(polsC, u)
+
+	invGamma := new(big.Int).ModInverse(toxic.KGamma, types.R)
+	invDelta := new(big.Int).ModInverse(toxic.KDelta, types.R)
+
+	pk.VkAlpha1 = new(bn256.G1).ScalarBaseMult(toxic.KAlpha)
+	pk.VkBeta1 = new(bn256.G1).ScalarBaseMult(toxic.KBeta)
+	pk.VkDelta1 = new(bn256.G1).ScalarBaseMult(toxic.KDelta)
+	pk.VkBeta2 = new(bn256.G2).ScalarBaseMult(toxic.KBeta)
+	pk.VkDelta2 = new(bn256.G2).ScalarBaseMult(toxic.KDelta)
+
+	var vk types.Vk
+	vk.Alpha = new(bn256.G1).ScalarBaseMult(toxic.KAlpha)
+	vk.Beta = new(bn256.G2).ScalarBaseMult(toxic.KBeta)
+	vk.Gamma = new(bn256.G2).ScalarBaseMult(toxic.KGamma)
+	vk.Delta = new(bn256.G2).ScalarBaseMult(toxic.KDelta)
+
+	// k_i = (beta*a_i(t) + alpha*b_i(t) + c_i(t)) / (gamma|delta)
+	k := make([]*big.Int, r1cs.NVars)
+	for i := 0; i < r1cs.NVars; i++ {
+		k[i] = fAdd(fAdd(fMul(aT[i], toxic.KBeta), fMul(bT[i], toxic.KAlpha)), cT[i])
+		if i <= r1cs.NPublic {
+			k[i] = fMul(k[i], invGamma)
+		} else {
+			k[i] = fMul(k[i], invDelta)
+		}
+	}
+
+	pk.A = make([]*bn256.G1, r1cs.NVars)
+	pk.B1 = make([]*bn256.G1, r1cs.NVars)
+	pk.B2 = make([]*bn256.G2, r1cs.NVars)
+	pk.C = make([]*bn256.G1, r1cs.NVars)
+	vk.IC = make([]*bn256.G1, r1cs.NPublic+1)
+	parallel.For(r1cs.NVars, func(i int) {
+		pk.A[i] = new(bn256.G1).ScalarBaseMult(aT[i])
+		pk.B1[i] = new(bn256.G1).ScalarBaseMult(bT[i])
+		pk.B2[i] = new(bn256.G2).ScalarBaseMult(bT[i])
+		if i <= r1cs.NPublic {
+			pk.C[i] = new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+			vk.IC[i] = new(bn256.G1).ScalarBaseMult(k[i])
+		} else {
+			pk.C[i] = new(bn256.G1).ScalarBaseMult(k[i])
+		}
+	})
+
+	// HExps_i = t^i * z(t) / delta
+	zod := fMul(zT, invDelta)
+	hScalars := make([]*big.Int, pk.DomainSize+1)
+	hScalars[0] = zod
+	for i := 1; i < len(hScalars); i++ {
+		hScalars[i] = fMul(hScalars[i-1], toxic.T)
+	}
+	pk.HExps = make([]*bn256.G1, len(hScalars))
+	parallel.For(len(hScalars), func(i int) {
+		pk.HExps[i] = new(bn256.G1).ScalarBaseMult(hScalars[i])
+	})
+
+	return &pk, &vk, nil
+}
+
+func checkR1CS(r1cs *types.R1CS) error {
+	if r1cs.NVars < 1 {
+		return fmt.Errorf("R1CS must have at least the constant one wire")
+	}
+	if r1cs.Prime != nil && r1cs.Prime.Cmp(types.R) != 0 {
+		return fmt.Errorf("R1CS prime %s does not match the bn256 scalar field",
+			r1cs.Prime.String())
+	}
+	if r1cs.NPublic < 0 || r1cs.NPublic >= r1cs.NVars {
+		return fmt.Errorf("invalid number of public signals: %d, nVars: %d",
+			r1cs.NPublic, r1cs.NVars)
+	}
+	for c, constraint := range r1cs.Constraints {
+		for _, lc := range []types.LinearCombination{constraint.A, constraint.B,
+			constraint.C} {
+			for s, v := range lc {
+				if s < 0 || s >= r1cs.NVars {
+					return fmt.Errorf("constraint %d references wire %d out of range", c, s)
+				}
+				if v == nil {
+					return fmt.Errorf("constraint %d has a nil coefficient for wire %d", c, s)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func checkToxic(toxic *Toxic) error {
+	for _, v := range []*big.Int{toxic.T, toxic.KAlpha, toxic.KBeta, toxic.KGamma,
+		toxic.KDelta} {
+		if v == nil || new(big.Int).Mod(v, types.R).Sign() == 0 {
+			return fmt.Errorf("toxic values must be non zero field elements")
+		}
+	}
+	return nil
+}
+
+// calculatePolynomials returns the A and B polynomials of each wire, in
+// evaluation form over the domain. The A polynomials include the input
+// consistency constraints after the circuit constraints.
+func calculatePolynomials(r1cs *types.R1CS) ([]map[int]*big.Int, []map[int]*big.Int) {
+	polsA := make([]map[int]*big.Int, r1cs.NVars)
+	polsB := make([]map[int]*big.Int, r1cs.NVars)
+	for i := 0; i < r1cs.NVars; i++ {
+		polsA[i] = make(map[int]*big.Int)
+		polsB[i] = make(map[int]*big.Int)
+	}
+	for c, constraint := range r1cs.Constraints {
+		for s, v := range constraint.A {
+			polsA[s][c] = new(big.Int).Mod(v, types.R)
+		}
+		for s, v := range constraint.B {
+			polsB[s][c] = new(big.Int).Mod(v, types.R)
+		}
+	}
+	for i := 0; i < r1cs.NPublic+1; i++ {
+		polsA[i][len(r1cs.Constraints)+i] = big.NewInt(1)
+	}
+	return polsA, polsB
+}
+
+// evaluatePolynomials evaluates the polynomials given in evaluation form,
+// using the Lagrange basis evaluations u
+func evaluatePolynomials(pols []map[int]*big.Int, u []*big.Int) []*big.Int {
+	r := make([]*big.Int, len(pols))
+	for s := range pols {
+		r[s] = big.NewInt(0)
+		for c, v := range pols[s] {
+			r[s] = fAdd(r[s], fMul(u[c], v))
+		}
+	}
+	return r
+}
+
+// lagrangeAt returns the evaluations at t of the Lagrange basis polynomials
+// of the domain of size 2^bits, L_i(t) = w^i * z(t) / (m * (t - w^i))
+func lagrangeAt(bits int, t, zT *big.Int) []*big.Int {
+	m := 1 << bits
+	w := rootOfUnity(bits)
+	zOverM := fMul(zT, new(big.Int).ModInverse(big.NewInt(int64(m)), types.R))
+
+	u := make([]*big.Int, m)
+	wi := big.NewInt(1)
+	for i := 0; i < m; i++ {
+		den := new(big.Int).ModInverse(fSub(t, wi), types.R)
+		u[i] = fMul(fMul(zOverM, wi), den)
+		wi = fMul(wi, w)
+	}
+	return u
+}
+
+// rootOfUnity returns the primitive 2^bits root of unity used by the prover
+// FFT, derived from the generator 5 of the multiplicative group
+func rootOfUnity(bits int) *big.Int {
+	rem := new(big.Int).Sub(types.R, big.NewInt(1))
+	s := 0
+	for rem.Bit(0) == 0 {
+		s++
+		rem.Rsh(rem, 1)
+	}
+	w := fExp(big.NewInt(5), rem) //nolint:gomnd
+	for i := s; i > bits; i-- {
+		w = fMul(w, w)
+	}
+	return w
+}
+
+func fAdd(a, b *big.Int) *big.Int {
+	ab := new(big.Int).Add(a, b)
+	return ab.Mod(ab, types.R)
+}
+
+func fSub(a, b *big.Int) *big.Int {
+	ab := new(big.Int).Sub(a, b)
+	return ab.Mod(ab, types.R)
+}
+
+func fMul(a, b *big.Int) *big.Int {
+	ab := new(big.Int).Mul(a, b)
+	return ab.Mod(ab, types.R)
+}
+
+func fExp(base, e *big.Int) *big.Int {
+	return new(big.Int).Exp(base, e, types.R)
+}
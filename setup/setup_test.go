@@ -0,0 +1,85 @@
+package setup
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/prover"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+func TestGenerateTrustedSetup(t *testing.T) {
+	r1cs := testutil.CubicR1CS()
+	pk, vk, err := GenerateTrustedSetup(r1cs)
+	require.Nil(t, err)
+
+	assert.Equal(t, 8, pk.DomainSize)
+	assert.Equal(t, r1cs.NVars, len(pk.A))
+	assert.Equal(t, r1cs.NVars, len(pk.PolsA))
+	assert.Equal(t, pk.DomainSize+1, len(pk.HExps))
+	assert.Equal(t, r1cs.NPublic+1, len(vk.IC))
+
+	proof, pubSignals, err := prover.GenerateProof(pk, testutil.CubicWitness(3))
+	require.Nil(t, err)
+	assert.Equal(t, []*big.Int{big.NewInt(35)}, pubSignals)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// a proof for other public signals must not verify
+	assert.False(t, verifier.Verify(vk, proof, []*big.Int{big.NewInt(36)}))
+
+	// a witness that does not satisfy the constraints must not verify
+	w := testutil.CubicWitness(3)
+	w[3] = big.NewInt(10)
+	proof, pubSignals, err = prover.GenerateProof(pk, w)
+	require.Nil(t, err)
+	assert.False(t, verifier.Verify(vk, proof, pubSignals))
+}
+
+func TestGenerateTrustedSetupWithToxic(t *testing.T) {
+	toxic := &Toxic{
+		T:      big.NewInt(123),
+		KAlpha: big.NewInt(2),
+		KBeta:  big.NewInt(3),
+		KGamma: big.NewInt(4),
+		KDelta: big.NewInt(5),
+	}
+	pk0, vk0, err := GenerateTrustedSetupWithToxic(testutil.CubicR1CS(), toxic)
+	require.Nil(t, err)
+	pk1, vk1, err := GenerateTrustedSetupWithToxic(testutil.CubicR1CS(), toxic)
+	require.Nil(t, err)
+	assert.Equal(t, pk0.HExps[3].Marshal(), pk1.HExps[3].Marshal())
+	assert.Equal(t, vk0.IC[1].Marshal(), vk1.IC[1].Marshal())
+
+	proof, pubSignals, err := prover.GenerateProof(pk0, testutil.CubicWitness(7))
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk1, proof, pubSignals))
+
+	// t can not be in the evaluation domain
+	toxic.T = big.NewInt(1)
+	_, _, err = GenerateTrustedSetupWithToxic(testutil.CubicR1CS(), toxic)
+	assert.NotNil(t, err)
+
+	toxic.T = big.NewInt(0)
+	_, _, err = GenerateTrustedSetupWithToxic(testutil.CubicR1CS(), toxic)
+	assert.NotNil(t, err)
+}
+
+func TestGenerateTrustedSetupInvalidR1CS(t *testing.T) {
+	r1cs := testutil.CubicR1CS()
+	r1cs.Constraints[0].A[6] = big.NewInt(1)
+	_, _, err := GenerateTrustedSetup(r1cs)
+	assert.NotNil(t, err)
+
+	r1cs = testutil.CubicR1CS()
+	r1cs.NPublic = r1cs.NVars
+	_, _, err = GenerateTrustedSetup(r1cs)
+	assert.NotNil(t, err)
+
+	r1cs = testutil.CubicR1CS()
+	r1cs.Prime = big.NewInt(7)
+	_, _, err = GenerateTrustedSetup(r1cs)
+	assert.NotNil(t, err)
+}
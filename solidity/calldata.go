@@ -0,0 +1,31 @@
+package solidity
+
+import (
+	"math/big"
+
+	"github.com/vocdoni/go-snark/parsers"
+	"github.com/vocdoni/go-snark/types"
+	"golang.org/x/crypto/sha3"
+)
+
+// VerifyProofSignature is the signature of the verifyProof function of the
+// contract generated by GenerateVerifier
+const VerifyProofSignature = "verifyProof(uint256[2],uint256[2][2],uint256[2],uint256[])"
+
+// VerifyProofSelector returns the 4 bytes selector of the verifyProof function
+func VerifyProofSelector() []byte {
+	h := sha3.NewLegacyKeccak256()
+	_, _ = h.Write([]byte(VerifyProofSignature))
+	return h.Sum(nil)[:4]
+}
+
+// Calldata returns the ABI encoded call data of the verifyProof function of
+// the contract generated by GenerateVerifier for the proof and its public
+// signals, the selector followed by parsers.ProofToABI
+func Calldata(proof *types.Proof, pubSignals []*big.Int) ([]byte, error) {
+	args, err := parsers.ProofToABI(proof, pubSignals)
+	if err != nil {
+		return nil, err
+	}
+	return append(VerifyProofSelector(), args...), nil
+}
@@ -0,0 +1,117 @@
+package solidity
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+// Fork identifies the set of EVM precompiled contracts, which determines the
+// gas cost of the bn256 precompiles
+type Fork int
+
+const (
+	// Istanbul uses the bn256 precompiles gas costs of EIP-1108
+	Istanbul Fork = iota
+	// Byzantium uses the original bn256 precompiles gas costs of EIP-196 and
+	// EIP-197
+	Byzantium
+)
+
+// addresses of the bn256 precompiled contracts
+const (
+	bn256AddAddress       = 6
+	bn256ScalarMulAddress = 7
+	bn256PairingAddress   = 8
+)
+
+// PrecompileCall is a call to one of the bn256 precompiled contracts
+type PrecompileCall struct {
+	// Address is the address of the precompile: 6 (bn256Add), 7
+	// (bn256ScalarMul) or 8 (bn256Pairing)
+	Address byte
+	Input   []byte
+	Output  []byte
+	Gas     uint64
+}
+
+// PrecompileResult is the result of the verification of a proof with the
+// bn256 precompiled contracts
+type PrecompileResult struct {
+	Valid bool
+	// Gas is the gas used by the precompile calls, which does not include
+	// the gas of the rest of the execution of the verifier contract
+	Gas   uint64
+	Calls []PrecompileCall
+}
+
+// VerifyWithPrecompiles verifies the proof running the bn256 precompiled
+// contracts of the go-ethereum EVM with the same calls than the verifier
+// contract generated by GenerateVerifier: a bn256ScalarMul and a bn256Add
+// for each public input, and a bn256Pairing of the 4 pairs. The key, the
+// proof and the inputs are checked first with verifier.CheckWellFormed and the
+// options, so a key already validated with verifier.ValidateVk can skip its
+// checks with verifier.WithoutVkValidation.
+func VerifyWithPrecompiles(vk *types.Vk, proof *types.Proof, inputs []*big.Int,
+	fork Fork, opts ...verifier.Option) (*PrecompileResult, error) {
+	var precompiles map[common.Address]vm.PrecompiledContract
+	switch fork {
+	case Istanbul:
+		precompiles = vm.PrecompiledContractsIstanbul
+	case Byzantium:
+		precompiles = vm.PrecompiledContractsByzantium
+	default:
+		return nil, fmt.Errorf("unknown fork: %d", fork)
+	}
+	if err := verifier.CheckWellFormed(vk, proof, inputs, opts...); err != nil {
+		return nil, err
+	}
+
+	res := &PrecompileResult{}
+	run := func(address byte, input []byte) ([]byte, error) {
+		p := precompiles[common.BytesToAddress([]byte{address})]
+		output, err := p.Run(input)
+		if err != nil {
+			return nil, fmt.Errorf("precompile %d: %w", address, err)
+		}
+		call := PrecompileCall{Address: address, Input: input, Output: output,
+			Gas: p.RequiredGas(input)}
+		res.Calls = append(res.Calls, call)
+		res.Gas += call.Gas
+		return output, nil
+	}
+
+	vkX := vk.IC[0].Marshal()
+	for i, input := range inputs {
+		scalar := input.FillBytes(make([]byte, 32)) //nolint:gomnd
+		mul, err := run(bn256ScalarMulAddress, append(vk.IC[i+1].Marshal(), scalar...))
+		if err != nil {
+			return nil, err
+		}
+		if vkX, err = run(bn256AddAddress, append(vkX, mul...)); err != nil {
+			return nil, err
+		}
+	}
+
+	// e(-A, B) * e(alpha, beta) * e(vkX, gamma) * e(C, delta) == 1
+	var input []byte
+	input = append(input, new(bn256.G1).Neg(proof.A).Marshal()...)
+	input = append(input, proof.B.Marshal()...)
+	input = append(input, vk.Alpha.Marshal()...)
+	input = append(input, vk.Beta.Marshal()...)
+	input = append(input, vkX...)
+	input = append(input, vk.Gamma.Marshal()...)
+	input = append(input, proof.C.Marshal()...)
+	input = append(input, vk.Delta.Marshal()...)
+	output, err := run(bn256PairingAddress, input)
+	if err != nil {
+		return nil, err
+	}
+	res.Valid = new(big.Int).SetBytes(output).Cmp(big.NewInt(1)) == 0
+	return res, nil
+}
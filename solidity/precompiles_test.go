@@ -0,0 +1,67 @@
+package solidity
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/prover"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/types"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+func TestVerifyWithPrecompiles(t *testing.T) {
+	pk, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	proof, pubSignals, err := prover.GenerateProof(pk, testutil.CubicWitness(3))
+	require.Nil(t, err)
+
+	res, err := VerifyWithPrecompiles(vk, proof, pubSignals, Istanbul)
+	require.Nil(t, err)
+	assert.True(t, res.Valid)
+	// bn256ScalarMul, bn256Add and bn256Pairing of 4 pairs
+	require.Equal(t, 3, len(res.Calls))
+	assert.Equal(t, []byte{7, 6, 8}, []byte{res.Calls[0].Address, res.Calls[1].Address,
+		res.Calls[2].Address})
+	assert.Equal(t, 96, len(res.Calls[0].Input))
+	assert.Equal(t, 128, len(res.Calls[1].Input))
+	assert.Equal(t, 4*192, len(res.Calls[2].Input))
+	assert.Equal(t, uint64(6000+150+45000+4*34000), res.Gas)
+
+	res, err = VerifyWithPrecompiles(vk, proof, pubSignals, Byzantium)
+	require.Nil(t, err)
+	assert.True(t, res.Valid)
+	assert.Equal(t, uint64(40000+500+100000+4*80000), res.Gas)
+
+	res, err = VerifyWithPrecompiles(vk, proof, []*big.Int{big.NewInt(1)}, Istanbul)
+	require.Nil(t, err)
+	assert.False(t, res.Valid)
+
+	_, err = VerifyWithPrecompiles(vk, proof, nil, Istanbul)
+	assert.True(t, errors.Is(err, verifier.ErrInputCount))
+	_, err = VerifyWithPrecompiles(vk, proof, []*big.Int{types.R}, Istanbul)
+	assert.True(t, errors.Is(err, verifier.ErrInputOutOfField))
+	_, err = VerifyWithPrecompiles(vk, proof, pubSignals, Fork(5))
+	assert.NotNil(t, err)
+
+	// the key checks are skipped for an already validated key, not the ones
+	// of the proof
+	badVk := *vk
+	badVk.Alpha = new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	_, err = VerifyWithPrecompiles(&badVk, proof, pubSignals, Istanbul)
+	assert.True(t, errors.Is(err, verifier.ErrInvalidVk))
+	res, err = VerifyWithPrecompiles(&badVk, proof, pubSignals, Istanbul,
+		verifier.WithoutVkValidation())
+	require.Nil(t, err)
+	assert.False(t, res.Valid)
+	badProof := *proof
+	badProof.C = badVk.Alpha
+	_, err = VerifyWithPrecompiles(vk, &badProof, pubSignals, Istanbul,
+		verifier.WithoutVkValidation())
+	assert.True(t, errors.Is(err, verifier.ErrInvalidProof))
+}
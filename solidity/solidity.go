@@ -0,0 +1,224 @@
+package solidity
+
+import (
+	"io"
+	"math/big"
+	"text/template"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+	"github.com/vocdoni/go-snark/verifier"
+)
+
+// g1Point contains the decimal coordinates of a G1 point
+type g1Point struct {
+	X, Y string
+}
+
+// g2Point contains the decimal coordinates of a G2 point, with the imaginary
+// part first, as expected by the bn256 pairing precompile
+type g2Point struct {
+	X, Y [2]string
+}
+
+func newG1Point(p *bn256.G1) g1Point {
+	b := p.Marshal()
+	return g1Point{
+		X: new(big.Int).SetBytes(b[:32]).String(),
+		Y: new(big.Int).SetBytes(b[32:64]).String(),
+	}
+}
+
+func newG2Point(p *bn256.G2) g2Point {
+	b := p.Marshal()
+	return g2Point{
+		X: [2]string{new(big.Int).SetBytes(b[:32]).String(),
+			new(big.Int).SetBytes(b[32:64]).String()},
+		Y: [2]string{new(big.Int).SetBytes(b[64:96]).String(),
+			new(big.Int).SetBytes(b[96:128]).String()},
+	}
+}
+
+var verifierTemplate = template.Must(template.New("verifier").Parse(verifierSource))
+
+// GenerateVerifier writes to w the Solidity source of a self-contained
+// Groth16 verifier contract with the verification key embedded, which uses
+// the bn256 precompiles of the EVM. The verifyProof function of the contract
+// takes the proof in the format of parsers.ProofToSmartContractFormat and the
+// public signals, see Calldata.
+func GenerateVerifier(w io.Writer, vk *types.Vk) error {
+	if err := verifier.ValidateVk(vk); err != nil {
+		return err
+	}
+	data := struct {
+		Q, R   string
+		Alpha  g1Point
+		Beta   g2Point
+		Gamma  g2Point
+		Delta  g2Point
+		IC     []g1Point
+		NInput int
+	}{
+		Q:      bn256.P.String(),
+		R:      types.R.String(),
+		Alpha:  newG1Point(vk.Alpha),
+		Beta:   newG2Point(vk.Beta),
+		Gamma:  newG2Point(vk.Gamma),
+		Delta:  newG2Point(vk.Delta),
+		NInput: len(vk.IC) - 1,
+	}
+	for _, p := range vk.IC {
+		data.IC = append(data.IC, newG1Point(p))
+	}
+	return verifierTemplate.Execute(w, data)
+}
+
+const verifierSource = `// SPDX-License-Identifier: GPL-3.0
+// Code generated by go-snark. DO NOT EDIT.
+pragma solidity ^0.6.11;
+
+library Pairing {
+    uint256 constant PRIME_Q = {{.Q}};
+
+    struct G1Point {
+        uint256 X;
+        uint256 Y;
+    }
+
+    // Encoding of field elements is: X[0] * i + X[1]
+    struct G2Point {
+        uint256[2] X;
+        uint256[2] Y;
+    }
+
+    // negate returns -p, so that e(p, q) * e(negate(p), q) == 1
+    function negate(G1Point memory p) internal pure returns (G1Point memory) {
+        if (p.X == 0 && p.Y == 0) {
+            return G1Point(0, 0);
+        }
+        return G1Point(p.X, PRIME_Q - (p.Y % PRIME_Q));
+    }
+
+    // addition returns p1 + p2, using the bn256Add precompile
+    function addition(G1Point memory p1, G1Point memory p2) internal view returns (G1Point memory r) {
+        uint256[4] memory input;
+        input[0] = p1.X;
+        input[1] = p1.Y;
+        input[2] = p2.X;
+        input[3] = p2.Y;
+        bool success;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            success := staticcall(sub(gas(), 2000), 6, input, 0x80, r, 0x40)
+        }
+        require(success, "pairing-add-failed");
+    }
+
+    // scalarMul returns p * s, using the bn256ScalarMul precompile
+    function scalarMul(G1Point memory p, uint256 s) internal view returns (G1Point memory r) {
+        uint256[3] memory input;
+        input[0] = p.X;
+        input[1] = p.Y;
+        input[2] = s;
+        bool success;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            success := staticcall(sub(gas(), 2000), 7, input, 0x60, r, 0x40)
+        }
+        require(success, "pairing-mul-failed");
+    }
+
+    // pairing returns whether e(p1[0], p2[0]) * ... * e(p1[n], p2[n]) == 1,
+    // using the bn256Pairing precompile
+    function pairing(G1Point[] memory p1, G2Point[] memory p2) internal view returns (bool) {
+        require(p1.length == p2.length, "pairing-lengths-failed");
+        uint256 elements = p1.length;
+        uint256 inputSize = elements * 6;
+        uint256[] memory input = new uint256[](inputSize);
+        for (uint256 i = 0; i < elements; i++) {
+            input[i * 6 + 0] = p1[i].X;
+            input[i * 6 + 1] = p1[i].Y;
+            input[i * 6 + 2] = p2[i].X[0];
+            input[i * 6 + 3] = p2[i].X[1];
+            input[i * 6 + 4] = p2[i].Y[0];
+            input[i * 6 + 5] = p2[i].Y[1];
+        }
+        uint256[1] memory out;
+        bool success;
+        // solium-disable-next-line security/no-inline-assembly
+        assembly {
+            success := staticcall(sub(gas(), 2000), 8, add(input, 0x20), mul(inputSize, 0x20), out, 0x20)
+        }
+        require(success, "pairing-opcode-failed");
+        return out[0] != 0;
+    }
+}
+
+contract Verifier {
+    uint256 constant SNARK_SCALAR_FIELD = {{.R}};
+
+    struct VerifyingKey {
+        Pairing.G1Point alpha1;
+        Pairing.G2Point beta2;
+        Pairing.G2Point gamma2;
+        Pairing.G2Point delta2;
+        Pairing.G1Point[] IC;
+    }
+
+    function verifyingKey() internal pure returns (VerifyingKey memory vk) {
+        vk.alpha1 = Pairing.G1Point(
+            {{.Alpha.X}},
+            {{.Alpha.Y}}
+        );
+        vk.beta2 = Pairing.G2Point(
+            [{{index .Beta.X 0}}, {{index .Beta.X 1}}],
+            [{{index .Beta.Y 0}}, {{index .Beta.Y 1}}]
+        );
+        vk.gamma2 = Pairing.G2Point(
+            [{{index .Gamma.X 0}}, {{index .Gamma.X 1}}],
+            [{{index .Gamma.Y 0}}, {{index .Gamma.Y 1}}]
+        );
+        vk.delta2 = Pairing.G2Point(
+            [{{index .Delta.X 0}}, {{index .Delta.X 1}}],
+            [{{index .Delta.Y 0}}, {{index .Delta.Y 1}}]
+        );
+        vk.IC = new Pairing.G1Point[]({{len .IC}});
+{{- range $i, $p := .IC}}
+        vk.IC[{{$i}}] = Pairing.G1Point(
+            {{$p.X}},
+            {{$p.Y}}
+        );
+{{- end}}
+    }
+
+    // verifyProof returns whether the proof is valid for the {{.NInput}} public
+    // signals in input. The G2 point b has the imaginary part of each
+    // coordinate first.
+    function verifyProof(
+        uint256[2] memory a,
+        uint256[2][2] memory b,
+        uint256[2] memory c,
+        uint256[] memory input
+    ) public view returns (bool) {
+        VerifyingKey memory vk = verifyingKey();
+        require(input.length + 1 == vk.IC.length, "verifier-bad-input");
+        Pairing.G1Point memory vkX = vk.IC[0];
+        for (uint256 i = 0; i < input.length; i++) {
+            require(input[i] < SNARK_SCALAR_FIELD, "verifier-gte-snark-scalar-field");
+            vkX = Pairing.addition(vkX, Pairing.scalarMul(vk.IC[i + 1], input[i]));
+        }
+
+        Pairing.G1Point[] memory p1 = new Pairing.G1Point[](4);
+        Pairing.G2Point[] memory p2 = new Pairing.G2Point[](4);
+        p1[0] = Pairing.negate(Pairing.G1Point(a[0], a[1]));
+        p2[0] = Pairing.G2Point([b[0][0], b[0][1]], [b[1][0], b[1][1]]);
+        p1[1] = vk.alpha1;
+        p2[1] = vk.beta2;
+        p1[2] = vkX;
+        p2[2] = vk.gamma2;
+        p1[3] = Pairing.G1Point(c[0], c[1]);
+        p2[3] = vk.delta2;
+        return Pairing.pairing(p1, p2);
+    }
+}
+`
@@ -0,0 +1,77 @@
+package solidity
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil"
+	"github.com/vocdoni/go-snark/parsers"
+	"github.com/vocdoni/go-snark/prover"
+	"github.com/vocdoni/go-snark/setup"
+	"github.com/vocdoni/go-snark/types"
+)
+
+func TestGenerateVerifier(t *testing.T) {
+	_, vk, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+
+	var b bytes.Buffer
+	require.Nil(t, GenerateVerifier(&b, vk))
+	src := b.String()
+
+	assert.True(t, strings.HasPrefix(src, "// SPDX-License-Identifier"))
+	assert.Contains(t, src, "contract Verifier {")
+	assert.Contains(t, src, "vk.IC = new Pairing.G1Point[](2);")
+	assert.Contains(t, src, "uint256 constant SNARK_SCALAR_FIELD = "+types.R.String()+";")
+	alpha := newG1Point(vk.Alpha)
+	assert.Contains(t, src, fmt.Sprintf("Pairing.G1Point(\n            %s,\n            %s\n", alpha.X,
+		alpha.Y))
+	for _, p := range vk.IC {
+		ic := newG1Point(p)
+		assert.Contains(t, src, ic.X)
+		assert.Contains(t, src, ic.Y)
+	}
+	// the G2 coordinates have the imaginary part first
+	delta := newG2Point(vk.Delta)
+	vkStr := parsers.ProofToSmartContractFormat(&types.Proof{A: vk.Alpha, B: vk.Delta,
+		C: vk.Alpha})
+	assert.Equal(t, vkStr.B[0], delta.X[:])
+	assert.Equal(t, vkStr.B[1], delta.Y[:])
+	assert.Contains(t, src, fmt.Sprintf("[%s, %s],\n            [%s, %s]", delta.X[0],
+		delta.X[1], delta.Y[0], delta.Y[1]))
+
+	assert.NotNil(t, GenerateVerifier(&b, &types.Vk{}))
+}
+
+func TestCalldata(t *testing.T) {
+	pk, _, err := setup.GenerateTrustedSetup(testutil.CubicR1CS())
+	require.Nil(t, err)
+	proof, pubSignals, err := prover.GenerateProof(pk, testutil.CubicWitness(3))
+	require.Nil(t, err)
+
+	data, err := Calldata(proof, pubSignals)
+	require.Nil(t, err)
+	assert.Equal(t, 4+32*11, len(data))
+	// keccak256("verifyProof(uint256[2],uint256[2][2],uint256[2],uint256[])")
+	assert.Equal(t, VerifyProofSelector(), data[:4])
+	assert.Equal(t, "c32e370e", hex.EncodeToString(data[:4]))
+
+	words := make([]string, (len(data)-4)/32)
+	for i := range words {
+		words[i] = new(big.Int).SetBytes(data[4+32*i : 4+32*(i+1)]).String()
+	}
+	ps := parsers.ProofToSmartContractFormat(proof)
+	assert.Equal(t, []string{ps.A[0], ps.A[1], ps.B[0][0], ps.B[0][1], ps.B[1][0], ps.B[1][1],
+		ps.C[0], ps.C[1], "288", "1", pubSignals[0].String()}, words)
+
+	_, err = Calldata(proof, []*big.Int{types.R})
+	assert.NotNil(t, err)
+	_, err = Calldata(&types.Proof{}, pubSignals)
+	assert.NotNil(t, err)
+}
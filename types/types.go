@@ -101,3 +101,29 @@ type Vk struct {
 	Delta *bn256.G2
 	IC    []*bn256.G1
 }
+
+// LinearCombination is a sparse linear combination of the circuit wires,
+// mapping the wire index to its coefficient
+type LinearCombination map[int]*big.Int
+
+// Constraint is a R1CS constraint of the form A·w * B·w - C·w = 0
+type Constraint struct {
+	A LinearCombination
+	B LinearCombination
+	C LinearCombination
+}
+
+// R1CS is the Rank-1 Constraint System of a circuit. Wire 0 is the constant
+// one, followed by the NPublic public signals (outputs first, then public
+// inputs) and the private signals.
+type R1CS struct {
+	Prime       *big.Int
+	NVars       int
+	NOutputs    int
+	NPubInputs  int
+	NPrvInputs  int
+	NPublic     int
+	NLabels     int
+	Constraints []Constraint
+	WireToLabel []uint64
+}
@@ -0,0 +1,114 @@
+package verifier
+
+import (
+	"crypto/rand"
+	"math/big"
+	"sort"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// batchScalarBits is the size of the random scalars of the linear
+// combination, so an invalid batch passes with probability 2^-128
+const batchScalarBits = 128
+
+// BatchVerify verifies the Groth16 zkSNARK proofs of the same verification
+// key, where inputs[i] are the public inputs of proofs[i]. The pairing checks
+// of all the proofs are combined with random scalars, so the whole batch
+// needs len(proofs)+3 Miller loops and a single final exponentiation. When
+// the batch is not valid, it is split to identify the invalid proofs, whose
+// indexes are returned. Unless WithoutPointValidation is given, the points of
+// the key and the proofs are validated, and all the proofs are invalid when
+// the key is not valid. WithoutVkValidation skips only the checks of the key.
+func BatchVerify(vk *types.Vk, proofs []*types.Proof, inputs [][]*big.Int,
+	opts ...Option) (bool, []int) {
+	o := newOptions(opts)
+	if len(proofs) != len(inputs) || checkVk(vk) != nil ||
+		(o.validateVk() && validateVkPoints(vk) != nil) {
+		invalid := make([]int, len(proofs))
+		for i := range invalid {
+			invalid[i] = i
+		}
+		return false, invalid
+	}
+	var invalid, valid []int
+	for i := range proofs {
+		if checkProof(vk, proofs[i], inputs[i]) != nil ||
+			(!o.skipValidation && validateProofPoints(proofs[i]) != nil) {
+			invalid = append(invalid, i)
+		} else {
+			valid = append(valid, i)
+		}
+	}
+	invalid = append(invalid, bisectBatch(vk, proofs, inputs, valid)...)
+	if len(invalid) > 0 {
+		sort.Ints(invalid)
+		return false, invalid
+	}
+	return true, nil
+}
+
+// bisectBatch returns the indexes of the invalid proofs, checking the batch
+// and splitting it in halves when it is not valid. The points must be already
+// validated.
+func bisectBatch(vk *types.Vk, proofs []*types.Proof, inputs [][]*big.Int, idx []int) []int {
+	if len(idx) == 0 {
+		return nil
+	}
+	if len(idx) == 1 {
+		if Verify(vk, proofs[idx[0]], inputs[idx[0]], WithoutPointValidation()) {
+			return nil
+		}
+		return idx
+	}
+	ok, err := verifyBatch(vk, proofs, inputs, idx)
+	if err == nil && ok {
+		return nil
+	}
+	half := len(idx) / 2 //nolint:gomnd
+	return append(bisectBatch(vk, proofs, inputs, idx[:half]),
+		bisectBatch(vk, proofs, inputs, idx[half:])...)
+}
+
+// verifyBatch checks the random linear combination of the pairing checks of
+// the proofs of idx:
+// prod(e(-r_i·A_i, B_i)) · e(sum(r_i)·alpha, beta) · e(sum(r_i·vkX_i), gamma)
+// · e(sum(r_i·C_i), delta) == 1
+func verifyBatch(vk *types.Vk, proofs []*types.Proof, inputs [][]*big.Int,
+	idx []int) (bool, error) {
+	g1 := make([]*bn256.G1, 0, len(idx)+3) //nolint:gomnd
+	g2 := make([]*bn256.G2, 0, len(idx)+3) //nolint:gomnd
+
+	// scalars of the IC points, so that sum(r_i·vkX_i) is computed with
+	// len(vk.IC) scalar multiplications. The one of IC[0] is sum(r_i).
+	icScalars := make([]*big.Int, len(vk.IC))
+	for j := range icScalars {
+		icScalars[j] = big.NewInt(0)
+	}
+	c := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	maxR := new(big.Int).Lsh(big.NewInt(1), batchScalarBits)
+	for _, i := range idx {
+		r, err := rand.Int(rand.Reader, maxR)
+		if err != nil {
+			return false, err
+		}
+		g1 = append(g1, new(bn256.G1).Neg(new(bn256.G1).ScalarMult(proofs[i].A, r)))
+		g2 = append(g2, proofs[i].B)
+		c = new(bn256.G1).Add(c, new(bn256.G1).ScalarMult(proofs[i].C, r))
+
+		icScalars[0].Add(icScalars[0], r)
+		for j, input := range inputs[i] {
+			icScalars[j+1].Add(icScalars[j+1], new(big.Int).Mul(r, input))
+		}
+	}
+	vkX := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	for j := range vk.IC {
+		icScalars[j].Mod(icScalars[j], types.R)
+		vkX = new(bn256.G1).Add(vkX, new(bn256.G1).ScalarMult(vk.IC[j], icScalars[j]))
+	}
+
+	g1 = append(g1, new(bn256.G1).ScalarMult(vk.Alpha, icScalars[0]), vkX, c)
+	g2 = append(g2, vk.Beta, vk.Gamma, vk.Delta)
+	return bn256.PairingCheck(g1, g2), nil
+}
@@ -0,0 +1,68 @@
+package verifier
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/vocdoni/go-snark/internal/testutil/prooftest"
+	"github.com/vocdoni/go-snark/types"
+)
+
+func TestBatchVerify(t *testing.T) {
+	vk, proofs, inputs := prooftest.CubicProofs(t, 8)
+
+	for _, n := range []int{0, 1, 2, 8} {
+		ok, invalid := BatchVerify(vk, proofs[:n], inputs[:n])
+		assert.True(t, ok, fmt.Sprintf("n: %d", n))
+		assert.Empty(t, invalid)
+	}
+
+	// invalid proofs
+	badProofs := append([]*types.Proof{}, proofs...)
+	badProofs[1] = &types.Proof{A: proofs[1].A, B: proofs[1].B, C: proofs[2].C}
+	badProofs[6] = nil
+	badInputs := append([][]*big.Int{}, inputs...)
+	badInputs[3] = []*big.Int{new(big.Int).Add(inputs[3][0], big.NewInt(1))}
+	badInputs[4] = []*big.Int{types.R}
+	badInputs[5] = append(badInputs[5], big.NewInt(1))
+	ok, invalid := BatchVerify(vk, badProofs, badInputs)
+	assert.False(t, ok)
+	assert.Equal(t, []int{1, 3, 4, 5, 6}, invalid)
+
+	// the inputs are not mutated
+	ok, _ = BatchVerify(vk, proofs, inputs)
+	assert.True(t, ok)
+
+	ok, invalid = BatchVerify(vk, proofs, inputs[:7])
+	assert.False(t, ok)
+	assert.Equal(t, 8, len(invalid))
+}
+
+// the zero point must not make the batch pass
+func TestBatchVerifyZeroProof(t *testing.T) {
+	vk, proofs, inputs := prooftest.CubicProofs(t, 2)
+	zero := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	proofs[0] = &types.Proof{A: zero, B: proofs[0].B, C: zero}
+	ok, invalid := BatchVerify(vk, proofs, inputs)
+	assert.False(t, ok)
+	assert.Equal(t, []int{0}, invalid)
+}
+
+func BenchmarkBatchVerify(b *testing.B) {
+	vk, proofs, inputs := prooftest.CubicProofs(b, 64)
+	b.Run("Verify", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for j := range proofs {
+				Verify(vk, proofs[j], inputs[j])
+			}
+		}
+	})
+	b.Run("BatchVerify", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			BatchVerify(vk, proofs, inputs)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package verifier
+
+import (
+	"bytes"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// icWindow is the size in bits of the windows of the fixed-base tables of the
+// IC points
+const icWindow = 4
+
+// fixedBaseTable contains the multiples d·2^(icWindow·j)·P, for each digit
+// d in [1, 2^icWindow) and each window j of a 256 bits scalar, so that P·k is
+// computed with an addition per window and no doublings
+type fixedBaseTable [][]*bn256.G1
+
+func newFixedBaseTable(p *bn256.G1) fixedBaseTable {
+	nWindows := 256 / icWindow //nolint:gomnd
+	t := make(fixedBaseTable, nWindows)
+	base := new(bn256.G1).Set(p)
+	for j := range t {
+		t[j] = make([]*bn256.G1, 1<<icWindow-1)
+		t[j][0] = base
+		for d := 1; d < len(t[j]); d++ {
+			t[j][d] = new(bn256.G1).Add(t[j][d-1], base)
+		}
+		base = new(bn256.G1).Add(t[j][len(t[j])-1], base)
+	}
+	return t
+}
+
+// mul returns P·k, where k must be in [0, 2^256)
+func (t fixedBaseTable) mul(k *big.Int) *bn256.G1 {
+	var b [32]byte
+	k.FillBytes(b[:])
+	r := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	for j := range t {
+		// window j is the nibble j of the little-endian scalar
+		d := int(b[31-j/2]>>(4*uint(j%2))) & (1<<icWindow - 1) //nolint:gomnd
+		if d != 0 {
+			r = new(bn256.G1).Add(r, t[j][d-1])
+		}
+	}
+	return r
+}
+
+// negG2 returns -p in affine coordinates, so it is not modified by the
+// concurrent verifications. The result of G2.Neg can not be used in a Miller
+// loop when p is already affine, as its t coordinate is left as zero, so it is
+// decoded again from its encoding.
+func negG2(p *bn256.G2) (*bn256.G2, error) {
+	q := new(bn256.G2)
+	if _, err := q.Unmarshal(new(bn256.G2).Neg(p).Marshal()); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// PreparedVk is a verification key with the values that do not depend on the
+// proof precomputed: e(alpha, beta), the negated gamma and delta and the
+// fixed-base tables of the IC points. It is safe for concurrent use.
+type PreparedVk struct {
+	vk        *types.Vk
+	alphaBeta []byte
+	gammaNeg  *bn256.G2
+	deltaNeg  *bn256.G2
+	ic        []fixedBaseTable
+}
+
+// NewPreparedVk precomputes the values of the verification key used by every
+// verification. The points of the verification key are validated with
+// ValidateVk.
+func NewPreparedVk(vk *types.Vk) (*PreparedVk, error) {
+	if err := ValidateVk(vk); err != nil {
+		return nil, err
+	}
+	pvk := &PreparedVk{
+		vk:        vk,
+		alphaBeta: bn256.Pair(vk.Alpha, vk.Beta).Marshal(),
+		ic:        make([]fixedBaseTable, len(vk.IC)-1),
+	}
+	var err error
+	if pvk.gammaNeg, err = negG2(vk.Gamma); err != nil {
+		return nil, err
+	}
+	if pvk.deltaNeg, err = negG2(vk.Delta); err != nil {
+		return nil, err
+	}
+	for i := range pvk.ic {
+		pvk.ic[i] = newFixedBaseTable(vk.IC[i+1])
+	}
+	return pvk, nil
+}
+
+// Verify verifies the Groth16 zkSNARK proof
+func (pvk *PreparedVk) Verify(proof *types.Proof, inputs []*big.Int, opts ...Option) bool {
+	return pvk.CheckProof(proof, inputs, opts...) == nil
+}
+
+// CheckProof verifies the Groth16 zkSNARK proof, checking that
+// e(A, B)·e(vkX, -gamma)·e(C, -delta) == e(alpha, beta), and returns the same
+// errors than the CheckProof function
+func (pvk *PreparedVk) CheckProof(proof *types.Proof, inputs []*big.Int, opts ...Option) error {
+	if err := checkProof(pvk.vk, proof, inputs); err != nil {
+		return err
+	}
+	if !newOptions(opts).skipValidation {
+		if err := validateProofPoints(proof); err != nil {
+			return err
+		}
+	}
+	vkX := new(bn256.G1).Set(pvk.vk.IC[0])
+	for i := range inputs {
+		vkX = new(bn256.G1).Add(vkX, pvk.ic[i].mul(inputs[i]))
+	}
+
+	acc := millerNonZero(proof.A, proof.B)
+	for _, m := range []*bn256.GT{millerNonZero(vkX, pvk.gammaNeg),
+		millerNonZero(proof.C, pvk.deltaNeg)} {
+		if acc == nil {
+			acc = m
+		} else if m != nil {
+			acc = new(bn256.GT).Add(acc, m)
+		}
+	}
+	if acc == nil || !bytes.Equal(acc.Finalize().Marshal(), pvk.alphaBeta) {
+		return ErrPairing
+	}
+	return nil
+}
+
+// millerNonZero returns the Miller loop of the points, or nil when one of
+// them is the identity, as PairingCheck skips those pairs
+func millerNonZero(a *bn256.G1, b *bn256.G2) *bn256.GT {
+	if bytes.Equal(a.Marshal(), zeroG1) || bytes.Equal(b.Marshal(), zeroG2) {
+		return nil
+	}
+	return bn256.Miller(a, b)
+}
@@ -0,0 +1,90 @@
+package verifier
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil/prooftest"
+	"github.com/vocdoni/go-snark/parsers"
+	"github.com/vocdoni/go-snark/types"
+)
+
+func TestFixedBaseTable(t *testing.T) {
+	_, p, err := bn256.RandomG1(rand.Reader)
+	require.Nil(t, err)
+	table := newFixedBaseTable(p)
+	r, err := rand.Int(rand.Reader, types.R)
+	require.Nil(t, err)
+	for _, k := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(16),
+		new(big.Int).Sub(types.R, big.NewInt(1)), r} {
+		assert.Equal(t, new(bn256.G1).ScalarMult(p, k).Marshal(), table.mul(k).Marshal())
+	}
+}
+
+func TestPreparedVk(t *testing.T) {
+	vk, proofs, inputs := prooftest.CubicProofs(t, 2)
+	pvk, err := NewPreparedVk(vk)
+	require.Nil(t, err)
+
+	for i := range proofs {
+		assert.True(t, pvk.Verify(proofs[i], inputs[i]))
+		// verify again to check that the prepared key is not mutated
+		assert.Nil(t, pvk.CheckProof(proofs[i], inputs[i]))
+	}
+	assert.True(t, errors.Is(pvk.CheckProof(proofs[0], inputs[1]), ErrPairing))
+	assert.True(t, errors.Is(pvk.CheckProof(proofs[0], nil), ErrInputCount))
+	assert.True(t, errors.Is(pvk.CheckProof(nil, inputs[0]), ErrInvalidProof))
+
+	zero := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	assert.False(t, pvk.Verify(&types.Proof{A: zero, B: proofs[0].B, C: zero}, inputs[0]))
+
+	_, err = NewPreparedVk(&types.Vk{})
+	assert.True(t, errors.Is(err, ErrInvalidVk))
+}
+
+func TestPreparedVkParsed(t *testing.T) {
+	vk, proofs, inputs := prooftest.CubicProofs(t, 1)
+	// the points of a parsed key are affine, unlike the ones of the setup
+	g1 := func(p *bn256.G1) []string {
+		return parsers.ProofToString(&types.Proof{A: p, B: vk.Beta, C: p}).A
+	}
+	g2 := func(p *bn256.G2) [][]string {
+		return parsers.ProofToString(&types.Proof{A: vk.Alpha, B: p, C: vk.Alpha}).B
+	}
+	vs := parsers.VkString{Alpha: g1(vk.Alpha), Beta: g2(vk.Beta), Gamma: g2(vk.Gamma),
+		Delta: g2(vk.Delta)}
+	for _, p := range vk.IC {
+		vs.IC = append(vs.IC, g1(p))
+	}
+	vkJSON, err := json.Marshal(vs)
+	require.Nil(t, err)
+	parsedVk, err := parsers.ParseVk(vkJSON)
+	require.Nil(t, err)
+
+	pvk, err := NewPreparedVk(parsedVk)
+	require.Nil(t, err)
+	assert.Nil(t, pvk.CheckProof(proofs[0], inputs[0]))
+	assert.True(t, Verify(parsedVk, proofs[0], inputs[0]))
+}
+
+func BenchmarkPreparedVk(b *testing.B) {
+	vk, proofs, inputs := prooftest.CubicProofs(b, 1)
+	pvk, err := NewPreparedVk(vk)
+	require.Nil(b, err)
+	b.Run("Verify", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Verify(vk, proofs[0], inputs[0])
+		}
+	})
+	b.Run("PreparedVk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pvk.Verify(proofs[0], inputs[0])
+		}
+	})
+}
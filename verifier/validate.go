@@ -0,0 +1,223 @@
+package verifier
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/vocdoni/go-snark/types"
+)
+
+var (
+	// ErrNotOnCurve is returned, in a *PointError, when a point is not on
+	// the curve
+	ErrNotOnCurve = errors.New("point not on the curve")
+	// ErrNotInSubgroup is returned, in a *PointError, when a G2 point is not
+	// in the subgroup of order R
+	ErrNotInSubgroup = errors.New("point not in the prime order subgroup")
+	// ErrIdentity is returned, in a *PointError, when a point that can not
+	// be the identity is the identity
+	ErrIdentity = errors.New("point is the identity")
+)
+
+// PointError is returned when a point of a proof or a verification key is not
+// valid. It matches with errors.Is both the reason (ErrNotOnCurve,
+// ErrNotInSubgroup or ErrIdentity) and ErrInvalidProof or ErrInvalidVk.
+type PointError struct {
+	// Name is the name of the point, like "A" or "IC[1]"
+	Name string
+	Err  error
+	kind error
+}
+
+// Error implements the error interface for PointError
+func (e *PointError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", e.kind, e.Name, e.Err)
+}
+
+// Unwrap returns the reason of the error
+func (e *PointError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is a point error of a proof (ErrInvalidProof)
+// or of a verification key (ErrInvalidVk)
+func (e *PointError) Is(target error) bool {
+	return target == e.kind
+}
+
+// Option configures the verification
+type Option func(*options)
+
+type options struct {
+	skipValidation   bool
+	skipVkValidation bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithoutPointValidation skips the curve, subgroup and identity checks of the
+// points of the proof and the verification key. It must only be used when
+// they come from a trusted source, see BenchmarkPointValidation for the cost
+// of the checks.
+func WithoutPointValidation() Option {
+	return func(o *options) {
+		o.skipValidation = true
+	}
+}
+
+// WithoutVkValidation skips the curve, subgroup and identity checks of the
+// points of the verification key, while still checking the ones of the proof.
+// It is meant for a key already validated with ValidateVk, so its G2 subgroup
+// checks are not repeated on each verification of the same key.
+func WithoutVkValidation() Option {
+	return func(o *options) {
+		o.skipVkValidation = true
+	}
+}
+
+// validateVk returns whether the points of the verification key must be
+// validated
+func (o *options) validateVk() bool {
+	return !o.skipValidation && !o.skipVkValidation
+}
+
+// ValidateProof checks that the points of the proof are set, on the curve,
+// not the identity and, for B, in the G2 subgroup of order R
+func ValidateProof(proof *types.Proof) error {
+	if proof == nil {
+		return fmt.Errorf("%w: nil proof", ErrInvalidProof)
+	}
+	if proof.A == nil || proof.B == nil || proof.C == nil {
+		return fmt.Errorf("%w: missing A, B or C", ErrInvalidProof)
+	}
+	return validateProofPoints(proof)
+}
+
+// ValidateVk checks that the points of the verification key are set, on the
+// curve and in the G2 subgroup of order R, and that alpha, beta, gamma and
+// delta are not the identity
+func ValidateVk(vk *types.Vk) error {
+	if err := checkVk(vk); err != nil {
+		return err
+	}
+	return validateVkPoints(vk)
+}
+
+func validateProofPoints(proof *types.Proof) error {
+	if err := validateG1(proof.A, false); err != nil {
+		return &PointError{Name: "A", Err: err, kind: ErrInvalidProof}
+	}
+	if err := validateG2(proof.B); err != nil {
+		return &PointError{Name: "B", Err: err, kind: ErrInvalidProof}
+	}
+	if err := validateG1(proof.C, false); err != nil {
+		return &PointError{Name: "C", Err: err, kind: ErrInvalidProof}
+	}
+	return nil
+}
+
+func validateVkPoints(vk *types.Vk) error {
+	if err := validateG1(vk.Alpha, false); err != nil {
+		return &PointError{Name: "alpha", Err: err, kind: ErrInvalidVk}
+	}
+	for _, p := range []struct {
+		name  string
+		point *bn256.G2
+	}{{"beta", vk.Beta}, {"gamma", vk.Gamma}, {"delta", vk.Delta}} {
+		if err := validateG2(p.point); err != nil {
+			return &PointError{Name: p.name, Err: err, kind: ErrInvalidVk}
+		}
+	}
+	for i := range vk.IC {
+		if err := validateG1(vk.IC[i], true); err != nil {
+			return &PointError{Name: fmt.Sprintf("IC[%d]", i), Err: err, kind: ErrInvalidVk}
+		}
+	}
+	return nil
+}
+
+// validateG1 checks that the point is on the curve, decoding its encoding as
+// bn256 only checks it when unmarshaling. As the cofactor of G1 is 1, every
+// point of the curve is in the subgroup.
+func validateG1(p *bn256.G1, allowIdentity bool) error {
+	b := p.Marshal()
+	if bytes.Equal(b, zeroG1) {
+		if allowIdentity {
+			return nil
+		}
+		return ErrIdentity
+	}
+	if _, err := new(bn256.G1).Unmarshal(b); err != nil {
+		return ErrNotOnCurve
+	}
+	return nil
+}
+
+// validateG2 checks that the point is on the twist curve, not the identity and
+// in the subgroup of order R
+func validateG2(p *bn256.G2) error {
+	return validateG2Bytes(p.Marshal())
+}
+
+// validateG2Bytes checks the encoding of a G2 point. bn256 checks both the
+// curve and the subgroup membership, computing R·P, when unmarshaling, so the
+// curve equation is only evaluated to report the reason of the failure.
+func validateG2Bytes(b []byte) error {
+	if bytes.Equal(b, zeroG2) {
+		return ErrIdentity
+	}
+	if _, err := new(bn256.G2).Unmarshal(b); err != nil {
+		if len(b) == 128 && isOnTwist(b) { //nolint:gomnd
+			return ErrNotInSubgroup
+		}
+		return ErrNotOnCurve
+	}
+	return nil
+}
+
+// isOnTwist checks that the encoded point satisfies y^2 = x^3 + 3/(9+i),
+// where the Fp2 elements are encoded as the imaginary and the real parts
+func isOnTwist(b []byte) bool {
+	x := fp2{new(big.Int).SetBytes(b[32:64]), new(big.Int).SetBytes(b[:32])}
+	y := fp2{new(big.Int).SetBytes(b[96:128]), new(big.Int).SetBytes(b[64:96])}
+	y2 := y.mul(y)
+	x3 := x.mul(x).mul(x).add(twistB)
+	return y2[0].Cmp(x3[0]) == 0 && y2[1].Cmp(x3[1]) == 0
+}
+
+// fp2 is an element a + b·i of Fp2 = Fp[i]/(i^2 + 1)
+type fp2 [2]*big.Int
+
+func (a fp2) mul(b fp2) fp2 {
+	re := new(big.Int).Sub(new(big.Int).Mul(a[0], b[0]), new(big.Int).Mul(a[1], b[1]))
+	im := new(big.Int).Add(new(big.Int).Mul(a[0], b[1]), new(big.Int).Mul(a[1], b[0]))
+	return fp2{re.Mod(re, bn256.P), im.Mod(im, bn256.P)}
+}
+
+func (a fp2) add(b fp2) fp2 {
+	re := new(big.Int).Add(a[0], b[0])
+	im := new(big.Int).Add(a[1], b[1])
+	return fp2{re.Mod(re, bn256.P), im.Mod(im, bn256.P)}
+}
+
+// twistB is 3/(9+i) = 3·(9-i)/82
+var twistB = func() fp2 {
+	inv82 := new(big.Int).ModInverse(big.NewInt(82), bn256.P) //nolint:gomnd
+	re := new(big.Int).Mul(big.NewInt(27), inv82)             //nolint:gomnd
+	im := new(big.Int).Mul(big.NewInt(-3), inv82)             //nolint:gomnd
+	return fp2{re.Mod(re, bn256.P), im.Mod(im, bn256.P)}
+}()
+
+var (
+	zeroG1 = new(bn256.G1).ScalarBaseMult(big.NewInt(0)).Marshal()
+	zeroG2 = new(bn256.G2).ScalarBaseMult(big.NewInt(0)).Marshal()
+)
@@ -0,0 +1,137 @@
+package verifier
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil/prooftest"
+	"github.com/vocdoni/go-snark/types"
+)
+
+// twistPoint is a point of the twist curve, with x = 2 + i, that is not in
+// the subgroup of order R
+const twistPoint = "0000000000000000000000000000000000000000000000000000000000000001" +
+	"0000000000000000000000000000000000000000000000000000000000000002" +
+	"2b76c179599bb92a963dac85546a005a777f7c13f6a7b75d5918b6b5808f5fde" +
+	"101f7278419308b95099eca02dcee0c5381f4d26d1d62313f057167f064101ce"
+
+func TestValidate(t *testing.T) {
+	vk, proofs, inputs := prooftest.CubicProofs(t, 1)
+	proof, public := proofs[0], inputs[0]
+	require.Nil(t, ValidateVk(vk))
+	require.Nil(t, ValidateProof(proof))
+
+	zeroG1 := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	zeroG2 := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+
+	for _, tc := range []struct {
+		proof  *types.Proof
+		name   string
+		reason error
+	}{
+		{&types.Proof{A: zeroG1, B: proof.B, C: proof.C}, "A", ErrIdentity},
+		{&types.Proof{A: proof.A, B: zeroG2, C: proof.C}, "B", ErrIdentity},
+		{&types.Proof{A: proof.A, B: proof.B, C: zeroG1}, "C", ErrIdentity},
+	} {
+		for _, err := range []error{ValidateProof(tc.proof), CheckProof(vk, tc.proof, public)} {
+			assert.True(t, errors.Is(err, ErrInvalidProof))
+			assert.False(t, errors.Is(err, ErrInvalidVk))
+			assert.True(t, errors.Is(err, tc.reason))
+			var pointErr *PointError
+			require.True(t, errors.As(err, &pointErr))
+			assert.Equal(t, tc.name, pointErr.Name)
+		}
+		ok, invalid := BatchVerify(vk, []*types.Proof{tc.proof, proof},
+			[][]*big.Int{public, public})
+		assert.False(t, ok)
+		assert.Equal(t, []int{0}, invalid)
+	}
+
+	badVk := *vk
+	badVk.Alpha = zeroG1
+	err := CheckProof(&badVk, proof, public)
+	assert.True(t, errors.Is(err, ErrInvalidVk))
+	assert.True(t, errors.Is(err, ErrIdentity))
+	_, err = NewPreparedVk(&badVk)
+	assert.True(t, errors.Is(err, ErrIdentity))
+	badVk = *vk
+	badVk.Gamma = zeroG2
+	assert.True(t, errors.Is(ValidateVk(&badVk), ErrIdentity))
+	badVk = *vk
+	badVk.IC = []*bn256.G1{vk.IC[0], zeroG1}
+	assert.Nil(t, ValidateVk(&badVk))
+
+	// skipping the key validation still validates the proof
+	badVk = *vk
+	badVk.Alpha = zeroG1
+	err = CheckProof(&badVk, proof, public, WithoutVkValidation())
+	assert.True(t, errors.Is(err, ErrPairing))
+	err = CheckProof(vk, &types.Proof{A: proof.A, B: zeroG2, C: proof.C}, public,
+		WithoutVkValidation())
+	assert.True(t, errors.Is(err, ErrInvalidProof))
+	assert.True(t, errors.Is(err, ErrIdentity))
+	assert.Nil(t, CheckProof(vk, proof, public, WithoutVkValidation()))
+	ok, _ := BatchVerify(&badVk, []*types.Proof{proof}, [][]*big.Int{public},
+		WithoutVkValidation())
+	assert.False(t, ok)
+	ok, _ = BatchVerify(vk, []*types.Proof{proof}, [][]*big.Int{public}, WithoutVkValidation())
+	assert.True(t, ok)
+
+	// skipping the validation only leaves the pairing check
+	err = CheckProof(vk, &types.Proof{A: proof.A, B: zeroG2, C: proof.C}, public,
+		WithoutPointValidation())
+	assert.True(t, errors.Is(err, ErrPairing))
+	assert.Nil(t, CheckProof(vk, proof, public, WithoutPointValidation()))
+}
+
+func TestValidateG2Bytes(t *testing.T) {
+	b, err := hex.DecodeString(twistPoint)
+	require.Nil(t, err)
+	assert.Equal(t, ErrNotInSubgroup, validateG2Bytes(b))
+	b[127]++
+	assert.Equal(t, ErrNotOnCurve, validateG2Bytes(b))
+
+	_, g, err := bn256.RandomG2(rand.Reader)
+	require.Nil(t, err)
+	b = g.Marshal()
+	assert.True(t, isOnTwist(b))
+	assert.Nil(t, validateG2Bytes(b))
+	assert.Equal(t, ErrIdentity, validateG2Bytes(make([]byte, 128)))
+}
+
+func BenchmarkPointValidation(b *testing.B) {
+	vk, proofs, inputs := prooftest.CubicProofs(b, 1)
+	pvk, err := NewPreparedVk(vk)
+	require.Nil(b, err)
+	b.Run("Verify", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Verify(vk, proofs[0], inputs[0])
+		}
+	})
+	b.Run("VerifyWithoutVkValidation", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Verify(vk, proofs[0], inputs[0], WithoutVkValidation())
+		}
+	})
+	b.Run("VerifyWithoutPointValidation", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Verify(vk, proofs[0], inputs[0], WithoutPointValidation())
+		}
+	})
+	b.Run("PreparedVk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pvk.Verify(proofs[0], inputs[0])
+		}
+	})
+	b.Run("PreparedVkWithoutPointValidation", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			pvk.Verify(proofs[0], inputs[0], WithoutPointValidation())
+		}
+	})
+}
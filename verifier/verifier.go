@@ -1,6 +1,7 @@
 package verifier
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 
@@ -8,6 +9,22 @@ import (
 	"github.com/vocdoni/go-snark/types"
 )
 
+var (
+	// ErrInvalidVk is returned when the verification key is malformed
+	ErrInvalidVk = errors.New("invalid verification key")
+	// ErrInvalidProof is returned when the proof is malformed
+	ErrInvalidProof = errors.New("invalid proof")
+	// ErrInputCount is returned when the number of public inputs does not
+	// match the verification key
+	ErrInputCount = errors.New("wrong number of public inputs")
+	// ErrInputOutOfField is returned when a public input is not in the
+	// [0, R) range of the field
+	ErrInputOutOfField = errors.New("public input out of the field")
+	// ErrPairing is returned when the pairing check of a well formed proof
+	// fails
+	ErrPairing = errors.New("pairing check failed")
+)
+
 // Vk is the Verification Key data structure
 type Vk struct {
 	Alpha *bn256.G1
@@ -18,22 +35,94 @@ type Vk struct {
 }
 
 // Verify verifies the Groth16 zkSNARK proof
-func Verify(vk *types.Vk, proof *types.Proof, inputs []*big.Int) bool {
-	if len(inputs)+1 != len(vk.IC) {
-		fmt.Println("len(inputs)+1 != len(vk.IC)")
-		return false
+func Verify(vk *types.Vk, proof *types.Proof, inputs []*big.Int, opts ...Option) bool {
+	return CheckProof(vk, proof, inputs, opts...) == nil
+}
+
+// CheckProof verifies the Groth16 zkSNARK proof, returning nil when it is
+// valid, or an error matching ErrInvalidVk, ErrInvalidProof, ErrInputCount,
+// ErrInputOutOfField or ErrPairing with errors.Is otherwise. Unless
+// WithoutPointValidation is given, the points of the proof and the key are
+// validated, returning a *PointError when they are not valid. When the same
+// key verifies many proofs, it can be validated once with ValidateVk and then
+// skipped with WithoutVkValidation, or prepared with NewPreparedVk.
+func CheckProof(vk *types.Vk, proof *types.Proof, inputs []*big.Int, opts ...Option) error {
+	if err := CheckWellFormed(vk, proof, inputs, opts...); err != nil {
+		return err
 	}
 	vkX := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
 	for i := 0; i < len(inputs); i++ {
-		// check input inside field
-		if inputs[i].Cmp(types.R) != -1 {
-			return false
-		}
 		vkX = new(bn256.G1).Add(vkX, new(bn256.G1).ScalarMult(vk.IC[i+1], inputs[i]))
 	}
 	vkX = new(bn256.G1).Add(vkX, vk.IC[0])
 
 	g1 := []*bn256.G1{new(bn256.G1).Neg(proof.A), vk.Alpha, vkX, proof.C}
 	g2 := []*bn256.G2{proof.B, vk.Beta, vk.Gamma, vk.Delta}
-	return bn256.PairingCheck(g1, g2)
+	if !bn256.PairingCheck(g1, g2) {
+		return ErrPairing
+	}
+	return nil
+}
+
+// CheckWellFormed checks the verification key, the proof and the public
+// inputs as CheckProof does before the pairing check, returning the same
+// errors, so other implementations of the pairing check can share them
+func CheckWellFormed(vk *types.Vk, proof *types.Proof, inputs []*big.Int, opts ...Option) error {
+	if err := checkVk(vk); err != nil {
+		return err
+	}
+	if err := checkProof(vk, proof, inputs); err != nil {
+		return err
+	}
+	o := newOptions(opts)
+	if o.validateVk() {
+		if err := validateVkPoints(vk); err != nil {
+			return err
+		}
+	}
+	if !o.skipValidation {
+		if err := validateProofPoints(proof); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkVk checks that all the points of the verification key are set
+func checkVk(vk *types.Vk) error {
+	if vk == nil {
+		return fmt.Errorf("%w: nil verification key", ErrInvalidVk)
+	}
+	if vk.Alpha == nil || vk.Beta == nil || vk.Gamma == nil || vk.Delta == nil {
+		return fmt.Errorf("%w: missing alpha, beta, gamma or delta", ErrInvalidVk)
+	}
+	if len(vk.IC) == 0 {
+		return fmt.Errorf("%w: empty IC", ErrInvalidVk)
+	}
+	for i := range vk.IC {
+		if vk.IC[i] == nil {
+			return fmt.Errorf("%w: missing IC[%d]", ErrInvalidVk, i)
+		}
+	}
+	return nil
+}
+
+// checkProof checks that the proof points are set and that the public inputs
+// match the verification key and are inside the field
+func checkProof(vk *types.Vk, proof *types.Proof, inputs []*big.Int) error {
+	if proof == nil {
+		return fmt.Errorf("%w: nil proof", ErrInvalidProof)
+	}
+	if proof.A == nil || proof.B == nil || proof.C == nil {
+		return fmt.Errorf("%w: missing A, B or C", ErrInvalidProof)
+	}
+	if len(inputs)+1 != len(vk.IC) {
+		return fmt.Errorf("%w: got %d, expected %d", ErrInputCount, len(inputs), len(vk.IC)-1)
+	}
+	for i, input := range inputs {
+		if input == nil || input.Sign() < 0 || input.Cmp(types.R) >= 0 {
+			return fmt.Errorf("%w: input %d", ErrInputOutOfField, i)
+		}
+	}
+	return nil
 }
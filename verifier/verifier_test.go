@@ -1,12 +1,17 @@
 package verifier
 
 import (
+	"errors"
 	"io/ioutil"
+	"math/big"
 	"testing"
 
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/vocdoni/go-snark/internal/testutil/prooftest"
 	"github.com/vocdoni/go-snark/parsers"
+	"github.com/vocdoni/go-snark/types"
 )
 
 func TestVerify(t *testing.T) {
@@ -39,6 +44,36 @@ func testVerifyCircuit(t *testing.T, circuit string) {
 	assert.True(t, v)
 }
 
+func TestCheckProof(t *testing.T) {
+	vk, proofs, inputs := prooftest.CubicProofs(t, 2)
+	proof, public := proofs[0], inputs[0]
+	assert.Nil(t, CheckProof(vk, proof, public))
+
+	badVk := *vk
+	badVk.Gamma = nil
+	assert.True(t, errors.Is(CheckProof(&badVk, proof, public), ErrInvalidVk))
+	badVk = *vk
+	badVk.IC = []*bn256.G1{vk.IC[0], nil}
+	assert.True(t, errors.Is(CheckProof(&badVk, proof, public), ErrInvalidVk))
+	assert.True(t, errors.Is(CheckProof(nil, proof, public), ErrInvalidVk))
+
+	assert.True(t, errors.Is(CheckProof(vk, nil, public), ErrInvalidProof))
+	assert.True(t, errors.Is(CheckProof(vk, &types.Proof{A: proof.A, B: proof.B}, public),
+		ErrInvalidProof))
+
+	assert.True(t, errors.Is(CheckProof(vk, proof, nil), ErrInputCount))
+	assert.True(t, errors.Is(CheckProof(vk, proof, append(public, big.NewInt(1))),
+		ErrInputCount))
+
+	for _, input := range []*big.Int{nil, big.NewInt(-1), types.R} {
+		assert.True(t, errors.Is(CheckProof(vk, proof, []*big.Int{input}), ErrInputOutOfField))
+	}
+
+	assert.True(t, errors.Is(CheckProof(vk, proof, inputs[1]), ErrPairing))
+	assert.True(t, errors.Is(CheckProof(vk, proofs[1], public), ErrPairing))
+	assert.False(t, Verify(vk, proofs[1], public))
+}
+
 func BenchmarkVerify(b *testing.B) {
 	// benchmark with circuit2 (10000 constraints)
 	proofJSON, err := ioutil.ReadFile("../testdata/circuit2/proof.json")